@@ -0,0 +1,132 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alecthomas/kingpin/v2"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+
+	"github.com/prometheus/prom2json"
+)
+
+var usage = `The path to JSON created by prom2json, if omitted, defaults to read from STDIN.
+
+Examples:
+
+	$ json2prom /tmp/metrics.json
+
+	$ prom2json http://my-prometheus-server:9000/metrics | json2prom --format=openmetrics
+
+`
+
+var formats = map[string]expfmt.Format{
+	"text":        expfmt.NewFormat(expfmt.TypeTextPlain),
+	"openmetrics": expfmt.NewFormat(expfmt.TypeOpenMetrics),
+	"protobuf":    expfmt.NewFormat(expfmt.TypeProtoDelim),
+}
+
+func main() {
+	format := kingpin.Flag("format", "The exposition format to write.").
+		Default("text").
+		Enum("text", "openmetrics", "protobuf")
+	escapingScheme := kingpin.Flag("escaping", "Sets the escaping scheme for metric and label names. Use 'allow-utf-8' to keep names unchanged.").
+		Default("allow-utf-8").
+		Enum(
+			"allow-utf-8",
+			"underscores",
+			"dots",
+			"values",
+		)
+
+	kingpin.CommandLine.UsageWriter(os.Stderr)
+	kingpin.Version(version.Print("json2prom"))
+	kingpin.HelpFlag.Short('h')
+
+	var input io.Reader
+	arg := kingpin.Arg("JSON_PATH", usage).String()
+
+	kingpin.Parse()
+
+	if *arg == "" {
+		// Use stdin on empty argument.
+		input = os.Stdin
+	} else {
+		f, err := os.Open(*arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error opening file:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	scheme, err := model.ToEscapingScheme(*escapingScheme)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	if err := convert(input, out, formats[*format].WithEscapingScheme(scheme)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing to stdout:", err)
+		os.Exit(1)
+	}
+}
+
+// convert reads JSON created by prom2json from in and writes the metric
+// families to out in the provided format.
+func convert(in io.Reader, out io.Writer, format expfmt.Format) error {
+	mfChan := make(chan *dto.MetricFamily, 1024)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- prom2json.ParseJSON(in, mfChan)
+	}()
+
+	var options []expfmt.EncoderOption
+	if format.FormatType() == expfmt.TypeOpenMetrics {
+		// Without this option, the created timestamps in the JSON
+		// would be dropped.
+		options = append(options, expfmt.WithCreatedLines())
+	}
+	enc := expfmt.NewEncoder(out, format, options...)
+	for mf := range mfChan {
+		if err := enc.Encode(mf); err != nil {
+			// Let ParseJSON return.
+			for range mfChan {
+			}
+			return fmt.Errorf("error encoding metrics: %w", err)
+		}
+	}
+	if err := <-errChan; err != nil {
+		return fmt.Errorf("error reading JSON: %w", err)
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("error encoding metrics: %w", err)
+		}
+	}
+	return nil
+}
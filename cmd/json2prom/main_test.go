@@ -0,0 +1,48 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConvertCreatedTimestamps(t *testing.T) {
+	in := `[{"name":"requests_total","type":"COUNTER","metrics":[{"value":"10","created_timestamp_ms":"1520430000000"}]}]`
+	var out bytes.Buffer
+	if err := convert(strings.NewReader(in), &out, formats["openmetrics"]); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# TYPE requests counter
+requests_total 10.0
+requests_created 1.52043e+09
+# EOF
+`
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"invalid JSON":  `[{"name":`,
+		"invalid value": `[{"name":"a","type":"GAUGE","metrics":[{"value":"one"}]}]`,
+	} {
+		var out bytes.Buffer
+		if err := convert(strings.NewReader(in), &out, formats["text"]); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
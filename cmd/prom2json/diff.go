@@ -0,0 +1,64 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus/prom2json"
+)
+
+// parseAnyFile works like prom2json.ParseReader, but also accepts the delimited
+// protobuf format and the JSON written by prom2json (with the default --output
+// and --numbers), as files to diff may have been saved in either.
+func parseAnyFile(in io.Reader, ch chan<- *dto.MetricFamily) error {
+	b, err := io.ReadAll(in)
+	if err != nil {
+		close(ch)
+		return fmt.Errorf("reading input failed: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		return prom2json.ParseJSON(bytes.NewReader(b), ch)
+	}
+	// The text formats practically never decode as a sequence of delimited
+	// MetricFamilies with a name each, so protobuf can safely be tried
+	// first.
+	if mfs, ok := parseProtobuf(b); ok {
+		defer close(ch)
+		for _, mf := range mfs {
+			ch <- mf
+		}
+		return nil
+	}
+	return prom2json.ParseReader(bytes.NewReader(b), ch)
+}
+
+// parseProtobuf parses b as the delimited protobuf format. ok is false if that
+// fails or b is empty.
+func parseProtobuf(b []byte) (mfs []*dto.MetricFamily, ok bool) {
+	r := bytes.NewReader(b)
+	for r.Len() > 0 {
+		mf := &dto.MetricFamily{}
+		if _, err := pbutil.ReadDelimited(r, mf); err != nil || mf.GetName() == "" {
+			return nil, false
+		}
+		mfs = append(mfs, mf)
+	}
+	return mfs, len(mfs) > 0
+}
@@ -14,36 +14,73 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/version"
 
 	"github.com/prometheus/prom2json"
 )
 
-var usage = `The path or URL to metrics to convert, if omitted, defaults to read from STDIN.
+var usage = `The paths or URLs to metrics to convert, if omitted, defaults to read from STDIN.
 
 Examples:
 
 	$ prom2json http://my-prometheus-server:9000/metrics
 
+	$ prom2json http://host-a:9100/metrics http://host-b:9100/metrics
+
 	$ curl http://my-prometheus-server:9000/metrics | prom2json
 	
 `
 
 func main() {
-	cert := kingpin.Flag("cert", "client certificate file").PlaceHolder("FILE").String()
-	key := kingpin.Flag("key", "client certificate's key file").PlaceHolder("FILE").String()
-	skipServerCertCheck := kingpin.Flag("accept-invalid-cert", "Accept any certificate during TLS handshake. Insecure, use only for testing.").Bool()
+	var clientFlags httpFlags
+	kingpin.Flag("cert", "client certificate file").PlaceHolder("FILE").StringVar(&clientFlags.cert)
+	kingpin.Flag("key", "client certificate's key file").PlaceHolder("FILE").StringVar(&clientFlags.key)
+	kingpin.Flag("accept-invalid-cert", "Accept any certificate during TLS handshake. Insecure, use only for testing.").BoolVar(&clientFlags.skipServerCertCheck)
+	kingpin.Flag("ca-file", "File with the CA certificates to verify the server certificate with.").PlaceHolder("FILE").StringVar(&clientFlags.caFile)
+	kingpin.Flag("ca-system-roots", "Trust the root CAs of the system in addition to those from --ca-file (or the tls_config in --http-config-file). The CA file is then read only once, so changes to it are not picked up with --interval.").BoolVar(&clientFlags.caSystemRoots)
+	kingpin.Flag("server-name", "Server name to verify the server certificate against, and to send via SNI.").PlaceHolder("NAME").StringVar(&clientFlags.serverName)
+	kingpin.Flag("tls-min-version", "Minimum TLS version to accept.").
+		PlaceHolder("VERSION").
+		EnumVar(&clientFlags.tlsMinVersion, "TLS10", "TLS11", "TLS12", "TLS13")
+	kingpin.Flag("proxy-url", "URL of the HTTP, HTTPS, or SOCKS5 proxy to use, e.g. 'socks5://localhost:1080'. Without it, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.").PlaceHolder("URL").StringVar(&clientFlags.proxyURL)
+	kingpin.Flag("no-proxy", "Comma-separated IP addresses, CIDR ranges, and domain names to not use the --proxy-url for.").PlaceHolder("HOSTS").StringVar(&clientFlags.noProxy)
+	auth := &clientFlags.auth
+	kingpin.Flag("bearer-token", "Bearer token to send in the Authorization header.").PlaceHolder("TOKEN").StringVar(&auth.bearerToken)
+	kingpin.Flag("bearer-token-file", "File to read the bearer token from.").PlaceHolder("FILE").StringVar(&auth.bearerTokenFile)
+	kingpin.Flag("basic-auth-user", "Username for HTTP basic authentication.").PlaceHolder("USER").StringVar(&auth.basicAuthUser)
+	kingpin.Flag("basic-auth-password-file", "File to read the password for HTTP basic authentication from.").PlaceHolder("FILE").StringVar(&auth.basicAuthPasswordFile)
+	kingpin.Flag("oauth2-client-id", "Client ID for OAuth2 client credentials authentication.").PlaceHolder("ID").StringVar(&auth.oauth2ClientID)
+	kingpin.Flag("oauth2-client-secret-file", "File to read the OAuth2 client secret from.").PlaceHolder("FILE").StringVar(&auth.oauth2ClientSecretFile)
+	kingpin.Flag("oauth2-token-url", "URL to fetch the OAuth2 token from.").PlaceHolder("URL").StringVar(&auth.oauth2TokenURL)
+	kingpin.Flag("oauth2-scope", "OAuth2 scope to request. Repeatable.").PlaceHolder("SCOPE").StringsVar(&auth.oauth2Scopes)
+	kingpin.Flag("oauth2-endpoint-param", "Additional parameter for the OAuth2 token request. Repeatable.").PlaceHolder("KEY=VALUE").StringMapVar(&auth.oauth2EndpointParams)
+	headers := kingpin.Flag("header", "Additional header to send, e.g. 'X-Scope-OrgID: tenant1'. Repeatable. Replaces a header prom2json would send otherwise, like Accept.").
+		Short('H').
+		PlaceHolder("'NAME: VALUE'").
+		Strings()
+	method := kingpin.Flag("method", "HTTP method to use. Defaults to POST with --data and to GET otherwise.").PlaceHolder("METHOD").String()
+	data := kingpin.Flag("data", "Body to send with the request, or '@FILE' to read it from a file. Sent with the Content-Type application/x-www-form-urlencoded unless set with --header.").
+		PlaceHolder("DATA").
+		String()
+	kingpin.Flag("http-config-file", "File with the HTTP client configuration in the format of the http_config of Prometheus, e.g. with tls_config, authorization, or proxy_url. Flags take precedence over the file.").PlaceHolder("FILE").StringVar(&clientFlags.configFile)
 	escapingScheme := kingpin.Flag("escaping", "Sets an escaping scheme in content negotiation. Use 'allow-utf-8' for full UTF-8 character support.").
 		PlaceHolder("SCHEME").
 		Enum(
@@ -52,63 +89,410 @@ func main() {
 			"dots",
 			"values",
 		)
+	output := kingpin.Flag("output", "The output format. 'json' writes a single JSON array once all metrics are read, 'ndjson' writes one JSON object per line as the metrics arrive. 'csv' and 'tsv' write one row per sample with one column per label name.").
+		Default("json").
+		Enum("json", "ndjson", "csv", "tsv")
+	perSeries := kingpin.Flag("per-series", "With --output=ndjson, write one line per series rather than per metric family.").Bool()
+	flatten := kingpin.Flag("flatten", "Write one object per sample (with summaries and histograms expanded like in the text format) rather than per metric family.").Bool()
+	matchers := kingpin.Flag("match", "Only convert series matching the PromQL series selector, e.g. 'http_requests_total{code=~\"5..\"}'. Repeatable, a series is converted if it matches any of the selectors.").
+		PlaceHolder("SELECTOR").
+		Strings()
+	includeName := kingpin.Flag("include-name", "Only convert metric families whose names match the regular expression.").PlaceHolder("REGEXP").String()
+	excludeName := kingpin.Flag("exclude-name", "Do not convert metric families whose names match the regular expression.").PlaceHolder("REGEXP").String()
+	numbers := kingpin.Flag("numbers", "How to write sample values, counts, sums, and timestamps. 'string' writes them as JSON strings, 'native' as JSON numbers.").
+		Default("string").
+		Enum("string", "native")
+	nonFinite := kingpin.Flag("non-finite", "With --numbers=native, the JSON value to write instead of NaN and ±Inf, e.g. 'null' or '\"NaN\"'.").
+		Default("null").
+		String()
+	histogramQuantiles := kingpin.Flag("histogram-quantile", "Add an estimate of the quantile (between 0 and 1) to each classic and native histogram, interpolated like the PromQL function histogram_quantile does it, e.g. '0.99'. Repeatable. Not written with --flatten.").
+		PlaceHolder("QUANTILE").
+		Float64List()
+	histogramMean := kingpin.Flag("histogram-mean", "Add the mean, i.e. the sum divided by the count, to each histogram. Not written with --flatten.").Bool()
+	nativeHistograms := kingpin.Flag("native-histograms", "How to write native histograms. 'buckets' writes their buckets like the Prometheus query API does, 'raw' their structure as exposed in the protobuf format, i.e. their schema, zero bucket, spans, and bucket counts, and 'both' writes both.").
+		Default("buckets").
+		Enum("buckets", "raw", "both")
+	histogramBuckets := kingpin.Flag("histogram-buckets", "Which buckets to write for histograms with both classic buckets and native spans. 'native' writes the native buckets only, 'classic' the classic buckets only, and 'both' writes the classic buckets as 'buckets' and the native ones as 'native_buckets', e.g. to verify a migration from classic to native histograms.").
+		Default("native").
+		Enum("native", "classic", "both")
+	timeout := kingpin.Flag("timeout", "The maximum time to scrape a URL, including reading the response, but not waiting for the output to be written. Sent to the target in the X-Prometheus-Scrape-Timeout-Seconds header. 0 means no timeout.").
+		Default("1m").
+		Duration()
+	parallelism := kingpin.Flag("parallelism", "The maximum number of targets scraped at the same time.").Default("8").Int()
+	targetLabel := kingpin.Flag("target-label", "If more than one target is provided, the name of the label to add to each series, with the target as its value. Set to '' to not add any label.").
+		Default("instance").
+		String()
+	interval := kingpin.Flag("interval", "Scrape repeatedly at this interval and write one JSON object per scrape and target as NDJSON, with the start time, duration, and success of the scrape. Does not work with STDIN.").
+		PlaceHolder("DURATION").
+		Duration()
+	count := kingpin.Flag("count", "With --interval, stop after this many scrapes. 0 means to scrape until interrupted.").Int()
+	rates := kingpin.Flag("rates", "With --interval, write the per-second rates of counters (and of the counts, sums, and buckets of summaries and histograms) and the deltas of gauges since the previous successful scrape rather than their values, starting with the second successful scrape of each target. A failed scrape is still written, with its error. --count then counts the scrapes after the first one, so '--count=1' scrapes twice.").Bool()
+	perTarget := kingpin.Flag("per-target", "With --output=json or --output=ndjson, write one object per target, containing the target, its metric families, and its error (if any), rather than all metric families merged.").Bool()
 
 	kingpin.CommandLine.UsageWriter(os.Stderr)
 	kingpin.Version(version.Print("prom2json"))
 	kingpin.HelpFlag.Short('h')
 
-	var input io.Reader
-	var err error
-	arg := kingpin.Arg("METRICS_PATH | METRICS_URL", usage).String()
+	convertCmd := kingpin.Command("convert", "Convert metrics to JSON. The default command.").Default()
+	targets := convertCmd.Arg("METRICS_PATH | METRICS_URL ...", usage).Strings()
+	diffCmd := kingpin.Command("diff", "Compare the metrics of OLD and NEW and write the differences as JSON, keyed by metric family name and label set. OLD and NEW may also be files with JSON written by prom2json.")
+	diffOld := diffCmd.Arg("OLD", "The path or URL of the old metrics.").Required().String()
+	diffNew := diffCmd.Arg("NEW", "The path or URL of the new metrics.").Required().String()
+	diffSummary := diffCmd.Flag("summary", "Write a human-readable summary rather than JSON.").Bool()
 
-	kingpin.Parse()
+	command := kingpin.Parse()
+	familyOptions := prom2json.FamilyOptions{
+		NativeFormat: map[string]prom2json.NativeHistogramFormat{
+			"buckets": prom2json.NativeBuckets,
+			"raw":     prom2json.NativeRaw,
+			"both":    prom2json.NativeBucketsAndRaw,
+		}[*nativeHistograms],
+		HistogramBuckets: map[string]prom2json.HistogramBuckets{
+			"native":  prom2json.NativeOnly,
+			"classic": prom2json.ClassicOnly,
+			"both":    prom2json.ClassicAndNative,
+		}[*histogramBuckets],
+	}
+	parseFile := prom2json.ParseReader
+	if command == diffCmd.FullCommand() {
+		*targets = []string{*diffOld, *diffNew}
+		parseFile = parseAnyFile
+	}
 
-	if *arg == "" {
+	if len(*targets) == 0 {
 		// Use stdin on empty argument.
-		input = os.Stdin
-	} else if url, urlErr := url.Parse(*arg); urlErr != nil || url.Scheme == "" {
-		// `url, err := url.Parse("/some/path.txt")` results in: `err == nil && url.Scheme == ""`
-		// Open file since arg appears not to be a valid URL (parsing error occurred or the scheme is missing).
-		if input, err = os.Open(*arg); err != nil {
-			fmt.Fprintln(os.Stderr, "error opening file:", err)
+		*targets = []string{""}
+	}
+	var needsTransport, hasUnixTargets bool
+	for _, t := range *targets {
+		if isURL(t) {
+			needsTransport = true
+		}
+		if strings.HasPrefix(t, "unix://") {
+			hasUnixTargets = true
+		}
+	}
+	fetcher := &prom2json.Fetcher{
+		Method:         *method,
+		Header:         http.Header{},
+		EscapingScheme: *escapingScheme,
+		UserAgent:      "prom2json/" + version.Version,
+	}
+	for _, h := range *headers {
+		name, value, ok := strings.Cut(h, ":")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "invalid --header %q, expected 'NAME: VALUE'\n", h)
+			os.Exit(1)
+		}
+		fetcher.Header.Add(name, strings.TrimSpace(value))
+	}
+	if file, ok := strings.CutPrefix(*data, "@"); ok {
+		var err error
+		if fetcher.Body, err = os.ReadFile(file); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading --data:", err)
 			os.Exit(1)
 		}
-	} else {
-		// Validate Client SSL arguments since arg appears to be a valid URL.
-		if (*cert != "" && *key == "") || (*cert == "" && *key != "") {
+	} else if *data != "" {
+		fetcher.Body = []byte(*data)
+	}
+	if needsTransport {
+		// Validate Client SSL arguments since an argument appears to be a valid URL.
+		if (clientFlags.cert != "" && clientFlags.key == "") || (clientFlags.cert == "" && clientFlags.key != "") {
 			fmt.Fprintf(os.Stderr, "%s\n with TLS client authentication: %s --cert /path/to/certificate --key /path/to/key METRICS_URL", usage, os.Args[0])
 			os.Exit(1)
 		}
+		httpConfig, err := newHTTPConfig(clientFlags, hasUnixTargets)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		opts := []config.HTTPClientOption{config.WithDialContextFunc(prom2json.DialContext)}
+		if clientFlags.caSystemRoots {
+			opts = append(opts, config.WithNewTLSConfigFunc(newTLSConfigWithSystemRoots))
+		}
+		if fetcher.Client, err = config.NewClientFromConfig(*httpConfig, "prom2json", opts...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
 
-	mfChan := make(chan *dto.MetricFamily, 1024)
-	// Missing input means we are reading from an URL.
-	if input != nil {
-		go func() {
-			if err := prom2json.ParseReader(input, mfChan); err != nil {
-				fmt.Fprintln(os.Stderr, "error reading metrics:", err)
+	if !json.Valid([]byte(*nonFinite)) {
+		fmt.Fprintf(os.Stderr, "--non-finite must be a JSON value, got %q\n", *nonFinite)
+		os.Exit(1)
+	}
+	for _, q := range *histogramQuantiles {
+		if !(q >= 0 && q <= 1) {
+			fmt.Fprintf(os.Stderr, "--histogram-quantile must be between 0 and 1, got %v\n", q)
+			os.Exit(1)
+		}
+	}
+	if *perTarget && (*output == "csv" || *output == "tsv") {
+		fmt.Fprintln(os.Stderr, "--per-target cannot be used with --output=csv or --output=tsv")
+		os.Exit(1)
+	}
+	watch := *interval > 0
+	if *count != 0 && !watch {
+		fmt.Fprintln(os.Stderr, "--count requires --interval")
+		os.Exit(1)
+	}
+	if *rates && !watch {
+		fmt.Fprintln(os.Stderr, "--rates requires --interval")
+		os.Exit(1)
+	}
+	if watch {
+		if *output == "csv" || *output == "tsv" {
+			fmt.Fprintln(os.Stderr, "--interval cannot be used with --output=csv or --output=tsv")
+			os.Exit(1)
+		}
+		if slices.Contains(*targets, "") {
+			fmt.Fprintln(os.Stderr, "--interval cannot be used with STDIN")
+			os.Exit(1)
+		}
+	}
+
+	filter, err := prom2json.NewFilter(*matchers, *includeName, *excludeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fetch := func(target string, ch chan<- *dto.MetricFamily) error {
+		switch {
+		case target == "":
+			if err := prom2json.ParseReader(os.Stdin, ch); err != nil {
+				return fmt.Errorf("error reading metrics: %w", err)
+			}
+			return nil
+		case isURL(target):
+			return fetchURL(fetcher, target, *timeout, ch)
+		default:
+			// Open file since target appears not to be a valid URL.
+			f, err := os.Open(target)
+			if err != nil {
+				close(ch)
+				return fmt.Errorf("error opening file: %w", err)
+			}
+			defer f.Close()
+			if err := parseFile(f, ch); err != nil {
+				return fmt.Errorf("error reading metrics: %w", err)
+			}
+			return nil
+		}
+	}
+	if len(*targets) == 1 || command == diffCmd.FullCommand() {
+		*targetLabel = ""
+	}
+	// process adds the target label and applies the filter, returning nil if
+	// nothing is left of mf.
+	process := func(s *scrape, mf *dto.MetricFamily) *dto.MetricFamily {
+		if *targetLabel != "" {
+			addTargetLabel(mf, *targetLabel, s.target)
+		}
+		return filter.Apply(mf)
+	}
+	// reportError reports the error of a scrape, if any, and returns it.
+	reportError := func(s *scrape) error {
+		err := <-s.err
+		if err != nil {
+			if len(*targets) > 1 {
+				fmt.Fprintf(os.Stderr, "target %q: %v\n", s.target, err)
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		return err
+	}
+	// statsFailed is set if histogram stats could not be added to some
+	// series, which are then written without them. It is separate from
+	// failed below as it is only accessed by the main goroutine.
+	statsFailed := false
+	// toJSON converts f into the items of a JSON array as requested by the
+	// flags.
+	toJSON := func(f *prom2json.Family) []any {
+		if len(*histogramQuantiles) > 0 || *histogramMean {
+			if err := f.AddHistogramStats(*histogramQuantiles, *histogramMean); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				statsFailed = true
+			}
+		}
+		var result []any
+		switch {
+		case *flatten && *numbers == "native":
+			for _, s := range prom2json.Flatten(f) {
+				result = append(result, prom2json.NativeSample{Sample: s, NonFinite: json.RawMessage(*nonFinite)})
+			}
+		case *flatten:
+			for _, s := range prom2json.Flatten(f) {
+				result = append(result, s)
+			}
+		case *numbers == "native":
+			result = append(result, prom2json.NativeNumbers{Family: f, NonFinite: json.RawMessage(*nonFinite)})
+		default:
+			result = append(result, f)
+		}
+		return result
+	}
+
+	failed := false
+	if command == diffCmd.FullCommand() {
+		scrapes := scrapeTargets(*targets, *parallelism, fetch)
+		var families [2][]*prom2json.Family
+		for i, s := range scrapes {
+			families[i] = collectFamilies(s, process, familyOptions)
+			if err := reportError(s); err != nil {
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		diff := prom2json.DiffFamilies(families[0], families[1])
+		if *diffSummary {
+			if err := diff.WriteSummary(os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, "error writing to stdout:", err)
 				os.Exit(1)
 			}
-		}()
-	} else {
-		transport, err := makeTransport(*cert, *key, *skipServerCertCheck)
+			return
+		}
+		writeJSON(diff)
+		return
+	}
+	if *perTarget || watch {
+		w := &watcher{
+			scrape: func() []*scrape { return scrapeTargets(*targets, *parallelism, fetch) },
+			collect: func(s *scrape) ([]*prom2json.Family, error) {
+				families := collectFamilies(s, process, familyOptions)
+				return families, reportError(s)
+			},
+			toJSON: toJSON,
+			count:  1,
+			rates:  *rates,
+		}
+		if watch {
+			ticker := time.NewTicker(*interval)
+			w.count, w.wait = *count, func() { <-ticker.C }
+			if *rates && w.count > 0 {
+				w.count++
+			}
+		}
+		out := bufio.NewWriter(os.Stdout)
+		enc := json.NewEncoder(out)
+		var results []targetResult
+		failed, err = w.run(func(r targetResult) error {
+			if *output == "json" && !watch {
+				results = append(results, r)
+				return nil
+			}
+			if err := enc.Encode(r); err != nil {
+				return fmt.Errorf("error marshaling JSON: %w", err)
+			}
+			if err := out.Flush(); err != nil {
+				return fmt.Errorf("error writing to stdout: %w", err)
+			}
+			return nil
+		})
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		go func() {
-			if err := prom2json.FetchMetricFamiliesWithEscapingScheme(*arg, mfChan, transport, *escapingScheme); err != nil {
+		if *output == "json" && !watch {
+			writeJSON(results)
+		}
+		if failed || statsFailed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	scrapes := scrapeTargets(*targets, *parallelism, fetch)
+	mfChan := make(chan *dto.MetricFamily, 1024)
+	go func() {
+		defer close(mfChan)
+		for _, s := range scrapes {
+			for mf := range s.families {
+				if mf = process(s, mf); mf != nil {
+					mfChan <- mf
+				}
+			}
+			if err := reportError(s); err != nil {
+				failed = true
+			}
+		}
+	}()
+
+	switch *output {
+	case "ndjson":
+		out := bufio.NewWriter(os.Stdout)
+		enc := prom2json.NewEncoder(out, *perSeries)
+		if *numbers == "native" {
+			enc.SetNativeNumbers(json.RawMessage(*nonFinite))
+		}
+		if *flatten {
+			enc.SetFlatten()
+		}
+		enc.SetHistogramStats(*histogramQuantiles, *histogramMean)
+		enc.SetNativeHistogramFormat(familyOptions.NativeFormat)
+		enc.SetHistogramBuckets(familyOptions.HistogramBuckets)
+		for mf := range mfChan {
+			if err := enc.Encode(mf); errors.Is(err, prom2json.ErrHistogramStats) {
 				fmt.Fprintln(os.Stderr, err)
+				statsFailed = true
+			} else if err != nil {
+				fmt.Fprintln(os.Stderr, "error marshaling JSON:", err)
+				os.Exit(1)
+			}
+			// Flush per metric family so that the output keeps up with
+			// the input.
+			if err := out.Flush(); err != nil {
+				fmt.Fprintln(os.Stderr, "error writing to stdout:", err)
 				os.Exit(1)
 			}
-		}()
+		}
+	case "csv", "tsv":
+		samples := []prom2json.Sample{}
+		for mf := range mfChan {
+			samples = append(samples, prom2json.Flatten(prom2json.NewFamilyWithOptions(mf, familyOptions))...)
+		}
+		if failed && len(*targets) == 1 {
+			// Nothing useful to write.
+			os.Exit(1)
+		}
+		comma := ','
+		if *output == "tsv" {
+			comma = '\t'
+		}
+		if err := prom2json.WriteCSV(os.Stdout, samples, comma); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing to stdout:", err)
+			os.Exit(1)
+		}
+	default:
+		result := []any{}
+		for mf := range mfChan {
+			result = append(result, toJSON(prom2json.NewFamilyWithOptions(mf, familyOptions))...)
+		}
+		if failed && len(*targets) == 1 {
+			// Nothing useful to write.
+			os.Exit(1)
+		}
+		writeJSON(result)
 	}
-
-	result := []*prom2json.Family{}
-	for mf := range mfChan {
-		result = append(result, prom2json.NewFamily(mf))
+	// Reading failed (here and above) is safe as mfChan has been closed
+	// after the last write to it.
+	if failed || statsFailed {
+		os.Exit(1)
 	}
-	jsonText, err := json.Marshal(result)
+}
+
+// isURL returns whether the target is a URL rather than a file path.
+func isURL(target string) bool {
+	// `url, err := url.Parse("/some/path.txt")` results in: `err == nil && url.Scheme == ""`
+	url, err := url.Parse(target)
+	return err == nil && url.Scheme != ""
+}
+
+// writeJSON writes v as JSON to stdout, followed by a newline.
+func writeJSON(v any) {
+	jsonText, err := json.Marshal(v)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error marshaling JSON:", err)
 		os.Exit(1)
@@ -120,25 +504,157 @@ func main() {
 	fmt.Println()
 }
 
-func makeTransport(
-	certificate string, key string,
-	skipServerCertCheck bool,
-) (*http.Transport, error) {
-	// Start with the DefaultTransport for sane defaults.
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	// Conservatively disable HTTP keep-alives as this program will only
-	// ever need a single HTTP request.
-	transport.DisableKeepAlives = true
-	// Timeout early if the server doesn't even return the headers.
-	transport.ResponseHeaderTimeout = time.Minute
-	tlsConfig := &tls.Config{InsecureSkipVerify: skipServerCertCheck}
-	if certificate != "" && key != "" {
-		cert, err := tls.LoadX509KeyPair(certificate, key)
+// newTLSConfigWithSystemRoots works like config.NewTLSConfigWithContext, but
+// adds the configured CA certificates to the root CAs of the system instead of
+// replacing them.
+func newTLSConfigWithSystemRoots(ctx context.Context, cfg *config.TLSConfig, opts ...config.TLSConfigOption) (*tls.Config, error) {
+	tlsConfig, err := config.NewTLSConfigWithContext(ctx, cfg, opts...)
+	if err != nil || tlsConfig.RootCAs == nil {
+		return tlsConfig, err
+	}
+	ca := []byte(cfg.CA)
+	if cfg.CAFile != "" {
+		if ca, err = os.ReadFile(cfg.CAFile); err != nil {
+			return nil, fmt.Errorf("unable to read CA cert: %w", err)
+		}
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load system root CAs: %w", err)
+	}
+	pool.AppendCertsFromPEM(ca)
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// loadHTTPConfig loads the HTTP client configuration from the provided YAML
+// file. Without a file, it returns the default configuration, which uses the
+// proxy configured by the environment.
+func loadHTTPConfig(file string) (*config.HTTPClientConfig, error) {
+	if file == "" {
+		cfg := config.DefaultHTTPClientConfig
+		cfg.ProxyFromEnvironment = true
+		return &cfg, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HTTP config file: %w", err)
+	}
+	cfg, err := config.LoadHTTPConfig(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTTP config file %q: %w", file, err)
+	}
+	// Relative paths in the file are relative to the file itself.
+	cfg.SetDirectory(filepath.Dir(file))
+	return cfg, nil
+}
+
+// httpFlags are the flags to configure the HTTP client.
+type httpFlags struct {
+	configFile          string
+	cert, key           string
+	skipServerCertCheck bool
+	caFile, serverName  string
+	caSystemRoots       bool
+	tlsMinVersion       string
+	proxyURL, noProxy   string
+	auth                authFlags
+}
+
+// newHTTPConfig returns the HTTP client configuration loaded from the file
+// provided by the flags, with the settings of the other flags applied on top.
+// The flags take precedence over the file. With --ca-system-roots, the CA file
+// is read right away and its content is used instead of the file name. If
+// hasUnixTargets is true, requests to localhost are never proxied. The result
+// is validated the same way Prometheus validates a scrape config.
+func newHTTPConfig(flags httpFlags, hasUnixTargets bool) (*config.HTTPClientConfig, error) {
+	cfg, err := loadHTTPConfig(flags.configFile)
+	if err != nil {
+		return nil, err
+	}
+	if flags.cert != "" {
+		cfg.TLSConfig.CertFile = flags.cert
+		cfg.TLSConfig.KeyFile = flags.key
+	}
+	if flags.skipServerCertCheck {
+		cfg.TLSConfig.InsecureSkipVerify = true
+	}
+	if flags.caFile != "" {
+		cfg.TLSConfig.CA, cfg.TLSConfig.CAFile = "", flags.caFile
+	}
+	if flags.caSystemRoots && cfg.TLSConfig.CAFile != "" {
+		// A client reloads a changed CA file into a pool without the
+		// root CAs of the system, so read the file once instead.
+		ca, err := os.ReadFile(cfg.TLSConfig.CAFile)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("unable to read CA cert: %w", err)
+		}
+		cfg.TLSConfig.CA, cfg.TLSConfig.CAFile = string(ca), ""
+	}
+	if flags.serverName != "" {
+		cfg.TLSConfig.ServerName = flags.serverName
+	}
+	if flags.tlsMinVersion != "" {
+		cfg.TLSConfig.MinVersion = config.TLSVersions[flags.tlsMinVersion]
+	}
+	if flags.proxyURL != "" {
+		u, err := url.Parse(flags.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --proxy-url: %w", err)
+		}
+		cfg.ProxyURL = config.URL{URL: u}
+		cfg.ProxyFromEnvironment = false
+	}
+	if flags.noProxy != "" {
+		cfg.NoProxy = flags.noProxy
+	}
+	if hasUnixTargets && cfg.ProxyURL.URL != nil {
+		// Requests via Unix domain sockets go to localhost, which must
+		// not be proxied.
+		cfg.NoProxy = strings.Trim(cfg.NoProxy+",localhost", ",")
+	}
+	flags.auth.apply(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid HTTP client configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// authFlags are the flags to configure the authentication of requests.
+type authFlags struct {
+	bearerToken, bearerTokenFile                           string
+	basicAuthUser, basicAuthPasswordFile                   string
+	oauth2ClientID, oauth2ClientSecretFile, oauth2TokenURL string
+	oauth2Scopes                                           []string
+	oauth2EndpointParams                                   map[string]string
+}
+
+// apply sets the authentication of cfg as configured by the flags. If any of
+// the flags is set, any authentication configured in cfg before is replaced.
+// Setting flags for more than one kind of authentication results in a cfg that
+// fails validation.
+func (a authFlags) apply(cfg *config.HTTPClientConfig) {
+	basicAuth := a.basicAuthUser != "" || a.basicAuthPasswordFile != ""
+	oauth2 := a.oauth2ClientID != "" || a.oauth2ClientSecretFile != "" || a.oauth2TokenURL != ""
+	if !basicAuth && !oauth2 && a.bearerToken == "" && a.bearerTokenFile == "" {
+		return
+	}
+	cfg.Authorization, cfg.BasicAuth, cfg.OAuth2 = nil, nil, nil
+	cfg.BearerToken = config.Secret(a.bearerToken)
+	cfg.BearerTokenFile = a.bearerTokenFile
+	if basicAuth {
+		cfg.BasicAuth = &config.BasicAuth{
+			Username:     a.basicAuthUser,
+			PasswordFile: a.basicAuthPasswordFile,
+		}
+	}
+	if oauth2 {
+		cfg.OAuth2 = &config.OAuth2{
+			ClientID:         a.oauth2ClientID,
+			ClientSecretFile: a.oauth2ClientSecretFile,
+			TokenURL:         a.oauth2TokenURL,
+			Scopes:           a.oauth2Scopes,
+			EndpointParams:   a.oauth2EndpointParams,
 		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
-	transport.TLSClientConfig = tlsConfig
-	return transport, nil
 }
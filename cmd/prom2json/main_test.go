@@ -0,0 +1,547 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestMain runs main instead of the tests if requested by runMain.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("PROM2JSON_TEST_ARGS"); ok {
+		os.Args = append([]string{"prom2json"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs main with the provided arguments in a subprocess and returns
+// what it wrote to stdout and its exit code.
+func runMain(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "PROM2JSON_TEST_ARGS="+strings.Join(args, "\n"))
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), 0
+}
+
+// auth returns the authentication related fields of cfg.
+func auth(cfg *config.HTTPClientConfig) []any {
+	return []any{cfg.Authorization, cfg.BasicAuth, cfg.OAuth2, cfg.BearerToken, cfg.BearerTokenFile}
+}
+
+func TestAuthFlags(t *testing.T) {
+	fromFile := &config.Authorization{Type: "Bearer", Credentials: "from-file"}
+	for name, tc := range map[string]struct {
+		flags    authFlags
+		expected *config.HTTPClientConfig
+		err      string
+	}{
+		"no flags": {
+			expected: &config.HTTPClientConfig{Authorization: fromFile},
+		},
+		"bearer token": {
+			flags: authFlags{bearerToken: "token"},
+			expected: &config.HTTPClientConfig{
+				Authorization: &config.Authorization{Type: "Bearer", Credentials: "token"},
+			},
+		},
+		"bearer token file": {
+			flags: authFlags{bearerTokenFile: "/token"},
+			expected: &config.HTTPClientConfig{
+				Authorization: &config.Authorization{Type: "Bearer", CredentialsFile: "/token"},
+			},
+		},
+		"basic auth": {
+			flags: authFlags{basicAuthUser: "user", basicAuthPasswordFile: "/password"},
+			expected: &config.HTTPClientConfig{
+				BasicAuth: &config.BasicAuth{Username: "user", PasswordFile: "/password"},
+			},
+		},
+		"oauth2": {
+			flags: authFlags{
+				oauth2ClientID:         "id",
+				oauth2ClientSecretFile: "/secret",
+				oauth2TokenURL:         "https://auth.example.org/token",
+				oauth2Scopes:           []string{"a", "b"},
+				oauth2EndpointParams:   map[string]string{"audience": "metrics"},
+			},
+			expected: &config.HTTPClientConfig{
+				OAuth2: &config.OAuth2{
+					ClientID:         "id",
+					ClientSecretFile: "/secret",
+					TokenURL:         "https://auth.example.org/token",
+					Scopes:           []string{"a", "b"},
+					EndpointParams:   map[string]string{"audience": "metrics"},
+				},
+			},
+		},
+		"oauth2 without token URL": {
+			flags: authFlags{oauth2ClientID: "id"},
+			err:   "oauth2 token_url must be configured",
+		},
+		"bearer token and bearer token file": {
+			flags: authFlags{bearerToken: "token", bearerTokenFile: "/token"},
+			err:   "at most one of bearer_token & bearer_token_file must be configured",
+		},
+		"bearer token and basic auth": {
+			flags: authFlags{bearerToken: "token", basicAuthUser: "user"},
+			err:   "at most one of basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
+		},
+		"basic auth and oauth2": {
+			flags: authFlags{basicAuthUser: "user", oauth2ClientID: "id", oauth2TokenURL: "https://auth.example.org/token"},
+			err:   "at most one of basic_auth, oauth2 & authorization must be configured",
+		},
+	} {
+		// The authentication from the file is replaced by any flag.
+		cfg := &config.HTTPClientConfig{Authorization: &config.Authorization{Type: "Bearer", Credentials: "from-file"}}
+		tc.flags.apply(cfg)
+		err := cfg.Validate()
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%s: expected error %q, got %v", name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(auth(tc.expected), auth(cfg)) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, spew.Sdump(auth(tc.expected)), spew.Sdump(auth(cfg)))
+		}
+	}
+}
+
+func TestAuthFlagsFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, secret, _ := r.BasicAuth(); secret != "oauth2-secret" {
+			http.Error(w, "invalid client secret "+secret, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"oauth2-token","token_type":"Bearer"}`))
+	}))
+	defer tokenServer.Close()
+
+	for name, tc := range map[string]struct {
+		flags    authFlags
+		expected string
+	}{
+		"bearer token file": {
+			flags:    authFlags{bearerTokenFile: writeFile("token", "file-token\n")},
+			expected: "Bearer file-token",
+		},
+		"basic auth password file": {
+			flags: authFlags{
+				basicAuthUser:         "user",
+				basicAuthPasswordFile: writeFile("password", "file-password\n"),
+			},
+			expected: "Basic " + base64.StdEncoding.EncodeToString([]byte("user:file-password")),
+		},
+		"oauth2 client secret file": {
+			flags: authFlags{
+				oauth2ClientID:         "id",
+				oauth2ClientSecretFile: writeFile("secret", "oauth2-secret\n"),
+				oauth2TokenURL:         tokenServer.URL,
+			},
+			expected: "Bearer oauth2-token",
+		},
+	} {
+		var got string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get("Authorization")
+		}))
+		cfg := config.DefaultHTTPClientConfig
+		tc.flags.apply(&cfg)
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		client, err := config.NewClientFromConfig(cfg, "prom2json")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		resp, err := client.Get(server.URL)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		resp.Body.Close()
+		if got != tc.expected {
+			t.Errorf("%s: expected Authorization header %q, got %q", name, tc.expected, got)
+		}
+	}
+
+	// A missing file fails the request rather than sending it without
+	// authentication.
+	cfg := config.DefaultHTTPClientConfig
+	authFlags{bearerTokenFile: filepath.Join(dir, "missing")}.apply(&cfg)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	client, err := config.NewClientFromConfig(cfg, "prom2json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(tokenServer.URL); err == nil {
+		t.Error("expected an error for a missing bearer token file")
+	}
+}
+
+func TestLoadHTTPConfig(t *testing.T) {
+	dir := t.TempDir()
+	for name, tc := range map[string]struct {
+		content  string
+		expected func(*config.HTTPClientConfig)
+		err      string
+	}{
+		"no file": {
+			expected: func(cfg *config.HTTPClientConfig) {
+				cfg.ProxyFromEnvironment = true
+			},
+		},
+		"relative paths": {
+			content: "tls_config:\n  ca_file: ca.pem\n  cert_file: /abs/cert.pem\n  key_file: key.pem\n",
+			expected: func(cfg *config.HTTPClientConfig) {
+				cfg.TLSConfig.CAFile = filepath.Join(dir, "ca.pem")
+				cfg.TLSConfig.CertFile = "/abs/cert.pem"
+				cfg.TLSConfig.KeyFile = filepath.Join(dir, "key.pem")
+			},
+		},
+		"authorization and proxy": {
+			content: "authorization:\n  credentials: secret\nproxy_url: http://proxy:3128\nno_proxy: localhost\n",
+			expected: func(cfg *config.HTTPClientConfig) {
+				cfg.Authorization = &config.Authorization{Type: "Bearer", Credentials: "secret"}
+				cfg.ProxyURL = config.URL{URL: &url.URL{Scheme: "http", Host: "proxy:3128"}}
+				cfg.NoProxy = "localhost"
+			},
+		},
+		"unknown field": {
+			content: "tls:\n  ca_file: ca.pem\n",
+			err:     "field tls not found",
+		},
+		"conflicting authentication": {
+			content: "bearer_token: secret\nbasic_auth:\n  username: user\n",
+			err:     "at most one of basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
+		},
+	} {
+		file := ""
+		if tc.content != "" {
+			file = filepath.Join(dir, "http.yml")
+			if err := os.WriteFile(file, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cfg, err := loadHTTPConfig(file)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected error containing %q, got %v", name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		expected := config.DefaultHTTPClientConfig
+		tc.expected(&expected)
+		if !reflect.DeepEqual(&expected, cfg) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, spew.Sdump(&expected), spew.Sdump(cfg))
+		}
+	}
+
+	if _, err := loadHTTPConfig(filepath.Join(dir, "missing.yml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestNewHTTPConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "http.yml")
+	content := `
+tls_config:
+  ca_file: ca.pem
+  cert_file: cert.pem
+  key_file: key.pem
+  server_name: from-file
+  min_version: TLS12
+authorization:
+  credentials: from-file
+proxy_url: http://file-proxy:3128
+no_proxy: from-file
+`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fromFile := func(cfg *config.HTTPClientConfig) {
+		cfg.TLSConfig = config.TLSConfig{
+			CAFile:     filepath.Join(dir, "ca.pem"),
+			CertFile:   filepath.Join(dir, "cert.pem"),
+			KeyFile:    filepath.Join(dir, "key.pem"),
+			ServerName: "from-file",
+			MinVersion: config.TLSVersions["TLS12"],
+		}
+		cfg.Authorization = &config.Authorization{Type: "Bearer", Credentials: "from-file"}
+		cfg.ProxyURL = config.URL{URL: &url.URL{Scheme: "http", Host: "file-proxy:3128"}}
+		cfg.NoProxy = "from-file"
+	}
+	for name, tc := range map[string]struct {
+		flags          httpFlags
+		hasUnixTargets bool
+		expected       func(*config.HTTPClientConfig)
+		err            string
+	}{
+		"flags only": {
+			flags: httpFlags{
+				cert:                "cert.pem",
+				key:                 "key.pem",
+				skipServerCertCheck: true,
+				caFile:              "ca.pem",
+				serverName:          "from-flag",
+				tlsMinVersion:       "TLS13",
+				proxyURL:            "socks5://flag-proxy:1080",
+				auth:                authFlags{bearerToken: "from-flag"},
+			},
+			expected: func(cfg *config.HTTPClientConfig) {
+				cfg.TLSConfig = config.TLSConfig{
+					CAFile:             "ca.pem",
+					CertFile:           "cert.pem",
+					KeyFile:            "key.pem",
+					ServerName:         "from-flag",
+					InsecureSkipVerify: true,
+					MinVersion:         config.TLSVersions["TLS13"],
+				}
+				cfg.Authorization = &config.Authorization{Type: "Bearer", Credentials: "from-flag"}
+				cfg.ProxyURL = config.URL{URL: &url.URL{Scheme: "socks5", Host: "flag-proxy:1080"}}
+			},
+		},
+		"file only": {
+			flags:    httpFlags{configFile: file},
+			expected: fromFile,
+		},
+		"flags override file": {
+			flags: httpFlags{
+				configFile:    file,
+				cert:          "/flag/cert.pem",
+				key:           "/flag/key.pem",
+				caFile:        "/flag/ca.pem",
+				serverName:    "from-flag",
+				tlsMinVersion: "TLS13",
+				proxyURL:      "http://flag-proxy:3128",
+				noProxy:       "from-flag",
+				auth:          authFlags{basicAuthUser: "user", basicAuthPasswordFile: "/flag/password"},
+			},
+			expected: func(cfg *config.HTTPClientConfig) {
+				fromFile(cfg)
+				cfg.TLSConfig.CAFile = "/flag/ca.pem"
+				cfg.TLSConfig.CertFile = "/flag/cert.pem"
+				cfg.TLSConfig.KeyFile = "/flag/key.pem"
+				cfg.TLSConfig.ServerName = "from-flag"
+				cfg.TLSConfig.MinVersion = config.TLSVersions["TLS13"]
+				cfg.ProxyURL = config.URL{URL: &url.URL{Scheme: "http", Host: "flag-proxy:3128"}}
+				cfg.NoProxy = "from-flag"
+				// The authentication flags replace the
+				// authorization of the file.
+				cfg.Authorization = nil
+				cfg.BasicAuth = &config.BasicAuth{Username: "user", PasswordFile: "/flag/password"}
+			},
+		},
+		"unix targets with proxy from file": {
+			flags:          httpFlags{configFile: file},
+			hasUnixTargets: true,
+			expected: func(cfg *config.HTTPClientConfig) {
+				fromFile(cfg)
+				cfg.NoProxy = "from-file,localhost"
+			},
+		},
+		"unix targets without proxy": {
+			hasUnixTargets: true,
+			expected: func(cfg *config.HTTPClientConfig) {
+				cfg.ProxyFromEnvironment = true
+			},
+		},
+		"conflicting authentication flags": {
+			flags: httpFlags{configFile: file, auth: authFlags{bearerToken: "token", basicAuthUser: "user"}},
+			err:   "invalid HTTP client configuration: at most one of basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
+		},
+		"no proxy flag with proxy from environment": {
+			flags: httpFlags{noProxy: "localhost"},
+			err:   "invalid HTTP client configuration: if proxy_from_environment is configured, no_proxy must not be configured",
+		},
+		"invalid proxy URL": {
+			flags: httpFlags{proxyURL: "http://proxy:port"},
+			err:   "invalid --proxy-url: ",
+		},
+		"missing file": {
+			flags: httpFlags{configFile: filepath.Join(dir, "missing.yml"), serverName: "from-flag"},
+			err:   "error reading HTTP config file: ",
+		},
+	} {
+		cfg, err := newHTTPConfig(tc.flags, tc.hasUnixTargets)
+		if tc.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+				t.Errorf("%s: expected error starting with %q, got %v", name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		expected := config.DefaultHTTPClientConfig
+		tc.expected(&expected)
+		if !reflect.DeepEqual(&expected, cfg) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, spew.Sdump(&expected), spew.Sdump(cfg))
+		}
+	}
+}
+
+func TestNewTLSConfigWithSystemRoots(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	// Without CA certificates, the root CAs of the system are used anyway.
+	tlsConfig, err := newTLSConfigWithSystemRoots(context.Background(), &config.TLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.RootCAs != nil {
+		t.Error("expected no root CAs without CA certificates")
+	}
+
+	expected, err := x509.SystemCertPool()
+	if err != nil {
+		t.Skip("no system root CAs:", err)
+	}
+	expected.AppendCertsFromPEM(ca)
+	tlsConfig, err = newTLSConfigWithSystemRoots(context.Background(), &config.TLSConfig{CA: string(ca)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expected.Equal(tlsConfig.RootCAs) {
+		t.Error("expected the root CAs of the system and the CA certificate")
+	}
+	if _, err := newTLSConfigWithSystemRoots(context.Background(), &config.TLSConfig{CA: "invalid"}); err == nil {
+		t.Error("expected an error for an invalid CA certificate")
+	}
+
+	// With --ca-system-roots, the CA file is read once, so changing it
+	// does not replace the root CAs with those from the file.
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(file, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := newHTTPConfig(httpFlags{caFile: file, caSystemRoots: true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TLSConfig.CA != string(ca) || cfg.TLSConfig.CAFile != "" {
+		t.Errorf("expected the content of the CA file, got CA %q and CA file %q", cfg.TLSConfig.CA, cfg.TLSConfig.CAFile)
+	}
+	client, err := config.NewClientFromConfig(*cfg, "prom2json", config.WithNewTLSConfigFunc(newTLSConfigWithSystemRoots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+		if err := os.WriteFile(file, []byte("invalid"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := newHTTPConfig(httpFlags{caFile: filepath.Join(t.TempDir(), "missing.pem"), caSystemRoots: true}, false); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+}
+
+func TestCSVHistogramBuckets(t *testing.T) {
+	// A histogram with both classic buckets and native spans.
+	mf := &dto.MetricFamily{
+		Name: proto.String("hist"),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{{
+			Histogram: &dto.Histogram{
+				SampleCount:   proto.Uint64(3),
+				SampleSum:     proto.Float64(4),
+				Bucket:        []*dto.Bucket{{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(1)}},
+				Schema:        proto.Int32(0),
+				ZeroThreshold: proto.Float64(0),
+				ZeroCount:     proto.Uint64(0),
+				PositiveSpan:  []*dto.BucketSpan{{Offset: proto.Int32(0), Length: proto.Uint32(2)}},
+				PositiveDelta: []int64{1, 1},
+			},
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited")
+		if _, err := pbutil.WriteDelimited(w, mf); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	classic := "name,timestamp_ms,value,le\nhist_bucket,,1,1\nhist_sum,,4,\nhist_count,,3,\n"
+	for histogramBuckets, expected := range map[string]string{
+		// Native buckets cannot be written as samples.
+		"native":  "name,timestamp_ms,value\nhist_sum,,4\nhist_count,,3\n",
+		"classic": classic,
+		"both":    classic,
+	} {
+		out, exitCode := runMain(t, "--output=csv", "--histogram-buckets="+histogramBuckets, server.URL)
+		if exitCode != 0 || out != expected {
+			t.Errorf("%s: expected\n%s\ngot exit code %d and\n%s", histogramBuckets, expected, exitCode, out)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/prometheus/prom2json"
+)
+
+// scrape is the ongoing scrape of a single target.
+type scrape struct {
+	target string
+	// families receives the metric families of the target and is closed
+	// once all of them have been sent.
+	families chan *dto.MetricFamily
+	// err receives the result of the scrape after families is closed.
+	err chan error
+	// start and duration may only be read after receiving from err.
+	start    time.Time
+	duration time.Duration
+}
+
+// targetResult is the JSON object written per scrape of a target with
+// --per-target or --interval.
+type targetResult struct {
+	Target          string  `json:"target"`
+	StartTime       string  `json:"start_time"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Success         bool    `json:"success"`
+	Error           string  `json:"error,omitempty"`
+	Families        []any   `json:"families"`
+}
+
+// scrapeTargets starts scraping the provided targets with fetch, with at most
+// parallelism scrapes running at the same time. The scrapes are started in the
+// order of the targets, so that consuming the returned scrapes in order never
+// blocks on a scrape that cannot start. fetch has to close the provided channel
+// when done.
+func scrapeTargets(
+	targets []string, parallelism int,
+	fetch func(target string, ch chan<- *dto.MetricFamily) error,
+) []*scrape {
+	scrapes := make([]*scrape, len(targets))
+	for i, t := range targets {
+		scrapes[i] = &scrape{
+			target:   t,
+			families: make(chan *dto.MetricFamily, 1024),
+			err:      make(chan error, 1),
+		}
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	slots := make(chan struct{}, parallelism)
+	go func() {
+		for _, s := range scrapes {
+			slots <- struct{}{}
+			go func() {
+				defer func() { <-slots }()
+				s.start = time.Now()
+				err := fetch(s.target, s.families)
+				s.duration = time.Since(s.start)
+				s.err <- err
+			}()
+		}
+	}()
+	return scrapes
+}
+
+// fetchURL fetches the metric families at url with f and sends them to ch,
+// which it closes when done. If timeout is positive, it limits the time of the
+// HTTP exchange, including reading the response, but not the time the metric
+// families wait to be consumed, e.g. while an earlier target is written to a
+// slow stdout. Until then, they are buffered.
+func fetchURL(f *prom2json.Fetcher, url string, timeout time.Duration, ch chan<- *dto.MetricFamily) error {
+	if timeout <= 0 {
+		return f.Fetch(context.Background(), url, ch)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	in := make(chan *dto.MetricFamily)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		forward(in, ch)
+	}()
+	err := f.Fetch(ctx, url, in)
+	<-done
+	return err
+}
+
+// forward sends everything received from in to out without ever blocking the
+// sender on in, and closes out once in is closed and everything is sent.
+func forward(in <-chan *dto.MetricFamily, out chan<- *dto.MetricFamily) {
+	var queue []*dto.MetricFamily
+	for in != nil || len(queue) > 0 {
+		var (
+			send chan<- *dto.MetricFamily
+			next *dto.MetricFamily
+		)
+		if len(queue) > 0 {
+			send, next = out, queue[0]
+		}
+		select {
+		case mf, ok := <-in:
+			if !ok {
+				in = nil
+				continue
+			}
+			queue = append(queue, mf)
+		case send <- next:
+			queue[0] = nil
+			queue = queue[1:]
+		}
+	}
+	close(out)
+}
+
+// collectFamilies returns the metric families of the scrape, processed with
+// process, with histograms represented as determined by opts.
+func collectFamilies(s *scrape, process func(*scrape, *dto.MetricFamily) *dto.MetricFamily, opts prom2json.FamilyOptions) []*prom2json.Family {
+	var result []*prom2json.Family
+	for mf := range s.families {
+		if mf = process(s, mf); mf != nil {
+			result = append(result, prom2json.NewFamilyWithOptions(mf, opts))
+		}
+	}
+	return result
+}
+
+// addTargetLabel adds a label with the provided name and value to all metrics
+// of mf. Like Prometheus does for target labels, an already existing label
+// with the same name is renamed by prefixing it with "exported_", as often as
+// needed to not clash with another existing label.
+func addTargetLabel(mf *dto.MetricFamily, name, value string) {
+	for _, m := range mf.Metric {
+		for _, lp := range m.Label {
+			if lp.GetName() != name {
+				continue
+			}
+			exported := "exported_" + name
+			for hasLabel(m, exported) {
+				exported = "exported_" + exported
+			}
+			lp.Name = proto.String(exported)
+		}
+		m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+}
+
+// hasLabel returns whether m has a label with the provided name.
+func hasLabel(m *dto.Metric, name string) bool {
+	for _, lp := range m.Label {
+		if lp.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
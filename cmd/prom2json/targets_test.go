@@ -0,0 +1,215 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/prometheus/prom2json"
+)
+
+func labelPairs(kv ...string) []*dto.LabelPair {
+	var result []*dto.LabelPair
+	for i := 0; i < len(kv); i += 2 {
+		result = append(result, &dto.LabelPair{Name: proto.String(kv[i]), Value: proto.String(kv[i+1])})
+	}
+	return result
+}
+
+func gauge(name string, labels ...string) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name: proto.String(name),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{
+			Label: labelPairs(labels...),
+			Gauge: &dto.Gauge{Value: proto.Float64(1)},
+		}},
+	}
+}
+
+func TestAddTargetLabel(t *testing.T) {
+	for name, tc := range map[string]struct {
+		labels   []string
+		expected []string
+	}{
+		"no clash": {
+			labels:   []string{"job", "a"},
+			expected: []string{"job", "a", "instance", "t"},
+		},
+		"clash": {
+			labels:   []string{"instance", "a"},
+			expected: []string{"exported_instance", "a", "instance", "t"},
+		},
+		"clash with exported label": {
+			labels:   []string{"exported_instance", "a", "instance", "b"},
+			expected: []string{"exported_instance", "a", "exported_exported_instance", "b", "instance", "t"},
+		},
+		"clash with twice exported label": {
+			labels:   []string{"exported_exported_instance", "a", "exported_instance", "b", "instance", "c"},
+			expected: []string{"exported_exported_instance", "a", "exported_instance", "b", "exported_exported_exported_instance", "c", "instance", "t"},
+		},
+	} {
+		mf := gauge("up", tc.labels...)
+		addTargetLabel(mf, "instance", "t")
+		if expected := labelPairs(tc.expected...); !reflect.DeepEqual(expected, mf.Metric[0].Label) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, spew.Sdump(expected), spew.Sdump(mf.Metric[0].Label))
+		}
+	}
+}
+
+// scrapeAll scrapes the targets with fetch, adds the instance label like the
+// convert command does for more than one target, and returns the metric
+// families and errors per target.
+func scrapeAll(targets []string, fetch func(string, chan<- *dto.MetricFamily) error) ([][]*prom2json.Family, []error) {
+	process := func(s *scrape, mf *dto.MetricFamily) *dto.MetricFamily {
+		addTargetLabel(mf, "instance", s.target)
+		return mf
+	}
+	var (
+		families [][]*prom2json.Family
+		errs     []error
+	)
+	for _, s := range scrapeTargets(targets, 1, fetch) {
+		families = append(families, collectFamilies(s, process, prom2json.FamilyOptions{}))
+		errs = append(errs, <-s.err)
+	}
+	return families, errs
+}
+
+func TestScrapeTargets(t *testing.T) {
+	fetch := func(target string, ch chan<- *dto.MetricFamily) error {
+		defer close(ch)
+		ch <- gauge("up", "instance", "exposed")
+		ch <- gauge("temperature")
+		return nil
+	}
+	families, errs := scrapeAll([]string{"a", "b"}, fetch)
+	for i, target := range []string{"a", "b"} {
+		if errs[i] != nil {
+			t.Errorf("target %s: unexpected error: %v", target, errs[i])
+		}
+		expected := []*prom2json.Family{
+			{
+				Name: "up",
+				Type: "GAUGE",
+				Metrics: []any{prom2json.Metric{
+					Labels: map[string]string{"exported_instance": "exposed", "instance": target},
+					Value:  "1",
+				}},
+			},
+			{
+				Name: "temperature",
+				Type: "GAUGE",
+				Metrics: []any{prom2json.Metric{
+					Labels: map[string]string{"instance": target},
+					Value:  "1",
+				}},
+			},
+		}
+		if !reflect.DeepEqual(expected, families[i]) {
+			t.Errorf("target %s: expected\n%s\ngot\n%s", target, spew.Sdump(expected), spew.Sdump(families[i]))
+		}
+	}
+}
+
+func TestScrapeTargetsPartialFailure(t *testing.T) {
+	errFailed := errors.New("scrape failed")
+	fetch := func(target string, ch chan<- *dto.MetricFamily) error {
+		defer close(ch)
+		if target == "bad" {
+			return errFailed
+		}
+		ch <- gauge("up")
+		return nil
+	}
+	families, errs := scrapeAll([]string{"good", "bad", "other"}, fetch)
+	if !reflect.DeepEqual([]error{nil, errFailed, nil}, errs) {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	for i, target := range []string{"good", "bad", "other"} {
+		var expected []*prom2json.Family
+		if target != "bad" {
+			expected = []*prom2json.Family{{
+				Name: "up",
+				Type: "GAUGE",
+				Metrics: []any{prom2json.Metric{
+					Labels: map[string]string{"instance": target},
+					Value:  "1",
+				}},
+			}}
+		}
+		if !reflect.DeepEqual(expected, families[i]) {
+			t.Errorf("target %s: expected\n%s\ngot\n%s", target, spew.Sdump(expected), spew.Sdump(families[i]))
+		}
+	}
+}
+
+func TestFetchURLTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(300 * time.Millisecond)
+			fmt.Fprint(w, "# TYPE up gauge\nup 1\n")
+			return
+		}
+		// More metric families than fit into the channel of a scrape.
+		for i := range 1100 {
+			fmt.Fprintf(w, "# TYPE metric_%d gauge\nmetric_%d 1\n", i, i)
+		}
+	}))
+	defer server.Close()
+
+	fetch := func(target string, ch chan<- *dto.MetricFamily) error {
+		return fetchURL(&prom2json.Fetcher{}, target, 500*time.Millisecond, ch)
+	}
+	scrapes := scrapeTargets([]string{server.URL + "/slow", server.URL + "/many"}, 2, fetch)
+	expected := []int{1, 1100}
+	for i, s := range scrapes {
+		n := 0
+		for range s.families {
+			n++
+			if i == 0 {
+				// Writing the output of the slow target
+				// makes /many wait for longer than the
+				// timeout.
+				time.Sleep(400 * time.Millisecond)
+			}
+		}
+		if err := <-s.err; err != nil {
+			t.Errorf("target %s: unexpected error: %v", s.target, err)
+		}
+		if n != expected[i] {
+			t.Errorf("target %s: expected %d metric families, got %d", s.target, expected[i], n)
+		}
+	}
+
+	// The timeout still applies to the HTTP exchange.
+	ch := make(chan *dto.MetricFamily, 1)
+	if err := fetchURL(&prom2json.Fetcher{}, server.URL+"/slow", 100*time.Millisecond, ch); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("expected no metric families")
+	}
+}
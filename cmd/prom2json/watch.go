@@ -0,0 +1,88 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/prom2json"
+)
+
+// watcher scrapes all targets repeatedly and produces a targetResult per scrape
+// and target, as done with --per-target and --interval.
+type watcher struct {
+	// scrape starts scraping all targets.
+	scrape func() []*scrape
+	// collect returns the metric families and the error of a scrape.
+	collect func(*scrape) ([]*prom2json.Family, error)
+	// toJSON converts a metric family into the items of Families.
+	toJSON func(*prom2json.Family) []any
+	// count is the number of times to scrape the targets. 0 means to
+	// scrape until interrupted.
+	count int
+	// If rates is true, the results contain the rates since the previous
+	// successful scrape of the target rather than the values. No result is
+	// produced for the first successful scrape of a target.
+	rates bool
+	// wait blocks until the next scrape is due.
+	wait func()
+}
+
+// run scrapes the targets and passes the results to emit, in the order of the
+// targets. It returns whether any scrape failed, or the first error returned
+// by emit.
+func (w *watcher) run(emit func(targetResult) error) (failed bool, err error) {
+	// The start time and the metric families of the previous successful
+	// scrape of each target for rates.
+	prevStart := map[string]time.Time{}
+	prevFamilies := map[string][]*prom2json.Family{}
+	for n := 1; ; n++ {
+		for _, s := range w.scrape() {
+			r := targetResult{Target: s.target, Families: []any{}}
+			families, err := w.collect(s)
+			if w.rates {
+				start, prev := prevStart[s.target], prevFamilies[s.target]
+				if err == nil {
+					prevStart[s.target], prevFamilies[s.target] = s.start, families
+				}
+				if err == nil && start.IsZero() {
+					// Nothing to compare with yet.
+					continue
+				}
+				if err == nil {
+					families = prom2json.Rates(prev, start, families, s.start)
+				} else {
+					families = nil
+				}
+			}
+			for _, f := range families {
+				r.Families = append(r.Families, w.toJSON(f)...)
+			}
+			r.StartTime = s.start.UTC().Format(time.RFC3339Nano)
+			r.DurationSeconds = s.duration.Seconds()
+			r.Success = err == nil
+			if err != nil {
+				r.Error = err.Error()
+				failed = true
+			}
+			if err := emit(r); err != nil {
+				return failed, err
+			}
+		}
+		if n == w.count {
+			return failed, nil
+		}
+		w.wait()
+	}
+}
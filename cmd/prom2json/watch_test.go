@@ -0,0 +1,225 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/prometheus/prom2json"
+)
+
+// fakeScraper scrapes targets by looking up the result of each scrape, in
+// order, in results. A nil MetricFamily stands for a failed scrape.
+type fakeScraper struct {
+	results map[string][]*dto.MetricFamily
+	scrapes int
+}
+
+func (f *fakeScraper) watcher(targets ...string) *watcher {
+	return &watcher{
+		scrape: func() []*scrape {
+			n := f.scrapes
+			f.scrapes++
+			return scrapeTargets(targets, 1, func(target string, ch chan<- *dto.MetricFamily) error {
+				defer close(ch)
+				mf := f.results[target][n]
+				if mf == nil {
+					return errors.New("scrape failed")
+				}
+				ch <- proto.Clone(mf).(*dto.MetricFamily)
+				return nil
+			})
+		},
+		collect: func(s *scrape) ([]*prom2json.Family, error) {
+			families := collectFamilies(s, func(_ *scrape, mf *dto.MetricFamily) *dto.MetricFamily { return mf }, prom2json.FamilyOptions{})
+			return families, <-s.err
+		},
+		toJSON: func(f *prom2json.Family) []any { return []any{f} },
+		wait:   func() {},
+	}
+}
+
+// runNDJSON runs w and returns the written NDJSON records.
+func runNDJSON(t *testing.T, w *watcher) ([]map[string]any, bool) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	failed, err := w.run(func(r targetResult) error { return enc.Encode(r) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records, failed
+}
+
+func counter(value float64) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name:   proto.String("requests_total"),
+		Type:   dto.MetricType_COUNTER.Enum(),
+		Metric: []*dto.Metric{{Counter: &dto.Counter{Value: proto.Float64(value)}}},
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	up := gauge("up")
+	f := &fakeScraper{results: map[string][]*dto.MetricFamily{
+		"a": {up, up, up, up},
+		"b": {up, nil, up, up},
+	}}
+	w := f.watcher("a", "b")
+	w.count = 3
+	records, failed := runNDJSON(t, w)
+	if !failed {
+		t.Error("expected a failed scrape to be reported")
+	}
+	if f.scrapes != 3 || len(records) != 6 {
+		t.Fatalf("expected 3 scrapes and 6 records, got %d and %d", f.scrapes, len(records))
+	}
+	for i, r := range records {
+		target, success := "a", true
+		if i%2 == 1 {
+			target, success = "b", i != 3
+		}
+		keys := []string{"duration_seconds", "families", "start_time", "success", "target"}
+		if !success {
+			keys = []string{"duration_seconds", "error", "families", "start_time", "success", "target"}
+		}
+		var got []string
+		for k := range r {
+			got = append(got, k)
+		}
+		slices.Sort(got)
+		if !reflect.DeepEqual(keys, got) {
+			t.Errorf("record %d: expected keys %v, got %v", i, keys, got)
+		}
+		if r["target"] != target || r["success"] != success {
+			t.Errorf("record %d: expected target %q and success %v, got %v and %v", i, target, success, r["target"], r["success"])
+		}
+		if _, err := time.Parse(time.RFC3339Nano, r["start_time"].(string)); err != nil {
+			t.Errorf("record %d: invalid start time: %v", i, err)
+		}
+		if d, ok := r["duration_seconds"].(float64); !ok || d < 0 {
+			t.Errorf("record %d: invalid duration %v", i, r["duration_seconds"])
+		}
+		families := r["families"].([]any)
+		if success && len(families) != 1 || !success && (len(families) != 0 || r["error"] != "scrape failed") {
+			t.Errorf("record %d: unexpected families %v and error %v", i, families, r["error"])
+		}
+	}
+
+	// Without failed scrapes, nothing is reported as failed.
+	f = &fakeScraper{results: map[string][]*dto.MetricFamily{"a": {up, up}}}
+	w = f.watcher("a")
+	w.count = 2
+	if _, failed := runNDJSON(t, w); failed {
+		t.Error("expected no failed scrape")
+	}
+}
+
+func TestWatcherRates(t *testing.T) {
+	f := &fakeScraper{results: map[string][]*dto.MetricFamily{
+		"a": {counter(1), counter(2), nil, counter(4)},
+		"b": {nil, counter(2), counter(3), counter(4)},
+	}}
+	w := f.watcher("a", "b")
+	w.count = 4
+	w.rates = true
+	records, failed := runNDJSON(t, w)
+	if !failed {
+		t.Error("expected a failed scrape to be reported")
+	}
+	type record struct {
+		target   string
+		success  bool
+		families int
+	}
+	expected := []record{
+		// The first successful scrape of a has no record, the failed
+		// one of b has.
+		{"b", false, 0},
+		// The first successful scrape of b has no record rather than a
+		// successful one without metric families.
+		{"a", true, 1},
+		{"a", false, 0},
+		{"b", true, 1},
+		// The rates of a are since its last successful scrape.
+		{"a", true, 1},
+		{"b", true, 1},
+	}
+	var got []record
+	for _, r := range records {
+		got = append(got, record{r["target"].(string), r["success"].(bool), len(r["families"].([]any))})
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected records\n%v\ngot\n%v", expected, got)
+	}
+}
+
+func TestWatcherEmitError(t *testing.T) {
+	up := gauge("up")
+	f := &fakeScraper{results: map[string][]*dto.MetricFamily{"a": {up, up}}}
+	w := f.watcher("a")
+	errEmit := errors.New("emit failed")
+	if _, err := w.run(func(targetResult) error { return errEmit }); err != errEmit {
+		t.Errorf("expected %v, got %v", errEmit, err)
+	}
+	if f.scrapes != 1 {
+		t.Errorf("expected to stop after 1 scrape, got %d", f.scrapes)
+	}
+}
+
+// TestWatchExitCode checks the exit code of --interval.
+func TestWatchExitCode(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "metrics.prom")
+	if err := os.WriteFile(good, []byte("# TYPE up gauge\nup 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		args     []string
+		records  int
+		exitCode int
+	}{
+		"success":       {args: []string{good}, records: 2},
+		"failed scrape": {args: []string{good, filepath.Join(dir, "missing.prom")}, records: 4, exitCode: 1},
+		"failed only":   {args: []string{filepath.Join(dir, "missing.prom")}, records: 2, exitCode: 1},
+		"rates":         {args: []string{"--rates", good}, records: 2},
+	} {
+		out, exitCode := runMain(t, append([]string{"--interval=1ms", "--count=2"}, tc.args...)...)
+		if exitCode != tc.exitCode {
+			t.Errorf("%s: expected exit code %d, got %d", name, tc.exitCode, exitCode)
+		}
+		if records := strings.Count(string(out), "\n"); records != tc.records {
+			t.Errorf("%s: expected %d records, got %d:\n%s", name, tc.records, records, out)
+		}
+	}
+}
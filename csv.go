@@ -0,0 +1,71 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+)
+
+// csvColumns are the columns always present in the output of WriteCSV.
+var csvColumns = []string{"name", "timestamp_ms", "value"}
+
+// WriteCSV writes the provided Samples (as created by Flatten) to w as a table
+// with a header row and one row per Sample, using comma as the field delimiter
+// (e.g. ',' for CSV and '\t' for TSV). Fields are quoted as described in RFC
+// 4180.
+//
+// The first columns are "name", "timestamp_ms", and "value", followed by one
+// column per label name found in any of the Samples, sorted by label name. A
+// label column is prefixed with "label_" if its name collides with one of the
+// first columns. Labels a Sample does not have result in empty fields.
+func WriteCSV(w io.Writer, samples []Sample, comma rune) error {
+	var labelNames []string
+	seen := map[string]struct{}{}
+	for _, s := range samples {
+		for name := range s.Labels {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				labelNames = append(labelNames, name)
+			}
+		}
+	}
+	slices.Sort(labelNames)
+
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	header := slices.Clone(csvColumns)
+	for _, name := range labelNames {
+		if slices.Contains(csvColumns, name) {
+			name = "label_" + name
+		}
+		header = append(header, name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for _, s := range samples {
+		record[0], record[1], record[2] = s.Name, s.TimestampMs, s.Value
+		for i, name := range labelNames {
+			record[len(csvColumns)+i] = s.Labels[name]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
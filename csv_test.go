@@ -0,0 +1,54 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	samples := []Sample{
+		{Name: "a", Labels: map[string]string{"x": "1,2", "name": "n"}, TimestampMs: "123", Value: "1"},
+		{Name: "a", Labels: map[string]string{"y": "say \"hi\""}, Value: "NaN"},
+		{Name: "b", Labels: map[string]string{"x": "line\nbreak"}, Value: "2"},
+	}
+	for _, tc := range []struct {
+		comma    rune
+		expected string
+	}{
+		{
+			comma: ',',
+			expected: "name,timestamp_ms,value,label_name,x,y\n" +
+				"a,123,1,n,\"1,2\",\n" +
+				"a,,NaN,,,\"say \"\"hi\"\"\"\n" +
+				"b,,2,,\"line\nbreak\",\n",
+		},
+		{
+			comma: '\t',
+			expected: "name\ttimestamp_ms\tvalue\tlabel_name\tx\ty\n" +
+				"a\t123\t1\tn\t1,2\t\n" +
+				"a\t\tNaN\t\t\t\"say \"\"hi\"\"\"\n" +
+				"b\t\t2\t\t\"line\nbreak\"\t\n",
+		},
+	} {
+		var out bytes.Buffer
+		if err := WriteCSV(&out, samples, tc.comma); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tc.expected {
+			t.Errorf("comma %q: unexpected output:\nexpected:\n%s\n\nactual:\n%s", tc.comma, tc.expected, &out)
+		}
+	}
+}
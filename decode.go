@@ -0,0 +1,523 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/prometheus/prom2json/histogram"
+)
+
+// ParseJSON consumes an io.Reader with JSON as created by prom2json, i.e. an
+// array of Family objects, and pushes the contained metric families to the
+// MetricFamily channel. It returns when all MetricFamilies are decoded and put
+// on the channel.
+func ParseJSON(in io.Reader, ch chan<- *dto.MetricFamily) error {
+	defer close(ch)
+	var families []*Family
+	if err := json.NewDecoder(in).Decode(&families); err != nil {
+		return fmt.Errorf("reading JSON failed: %w", err)
+	}
+	for _, f := range families {
+		mf, err := NewMetricFamily(f)
+		if err != nil {
+			return err
+		}
+		ch <- mf
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The Type of the Family determines
+// whether the Metrics are decoded as Metric, Summary, or Histogram. The
+// Buckets (and NativeBuckets) of a Histogram are decoded as map[string]string
+// for a classic histogram and as []NativeBucket for a native histogram. Labels
+// and Quantiles are never nil, as it is the case for a Family created by
+// NewFamily.
+func (f *Family) UnmarshalJSON(data []byte) error {
+	var jf struct {
+		Name    string            `json:"name"`
+		Help    string            `json:"help"`
+		Unit    string            `json:"unit"`
+		Type    string            `json:"type"`
+		Metrics []json.RawMessage `json:"metrics"`
+	}
+	if err := json.Unmarshal(data, &jf); err != nil {
+		return err
+	}
+	f.Name, f.Help, f.Unit, f.Type = jf.Name, jf.Help, jf.Unit, jf.Type
+	f.Metrics = make([]any, len(jf.Metrics))
+	for i, raw := range jf.Metrics {
+		var err error
+		switch f.Type {
+		case dto.MetricType_SUMMARY.String():
+			var s Summary
+			err = json.Unmarshal(raw, &s)
+			s.Labels = nonNilMap(s.Labels)
+			s.Quantiles = nonNilMap(s.Quantiles)
+			f.Metrics[i] = s
+		case dto.MetricType_HISTOGRAM.String(), dto.MetricType_GAUGE_HISTOGRAM.String():
+			var h struct {
+				Histogram
+				Buckets       json.RawMessage `json:"buckets"`
+				NativeBuckets json.RawMessage `json:"native_buckets"`
+			}
+			if err = json.Unmarshal(raw, &h); err == nil {
+				h.Histogram.Buckets, err = unmarshalBuckets(h.Buckets)
+			}
+			if err == nil {
+				h.Histogram.NativeBuckets, err = unmarshalBuckets(h.NativeBuckets)
+			}
+			h.Labels = nonNilMap(h.Labels)
+			nonNilExemplarLabels(h.Exemplars)
+			nonNilExemplarLabels(h.NativeExemplars)
+			f.Metrics[i] = h.Histogram
+		default:
+			var m Metric
+			err = json.Unmarshal(raw, &m)
+			m.Labels = nonNilMap(m.Labels)
+			nonNilExemplarLabels(m.Exemplars)
+			f.Metrics[i] = m
+		}
+		if err != nil {
+			return fmt.Errorf("decoding metric of metric family %q failed: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// unmarshalBuckets decodes classic buckets into a map[string]string and native
+// buckets into a []NativeBucket.
+func unmarshalBuckets(raw json.RawMessage) (any, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		var buckets map[string]string
+		if err := json.Unmarshal(raw, &buckets); err != nil {
+			return nil, err
+		}
+		return buckets, nil
+	}
+	var buckets []NativeBucket
+	if err := json.Unmarshal(raw, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+func nonNilMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+func nonNilExemplarLabels(exemplars []Exemplar) {
+	for i := range exemplars {
+		exemplars[i].Labels = nonNilMap(exemplars[i].Labels)
+	}
+}
+
+// NewMetricFamily consumes a Family and transforms it back into a MetricFamily
+// proto message. It is the inverse of NewFamily. The Metrics of the Family
+// have to be of the type Metric, Summary, or Histogram, as appropriate for the
+// Type of the Family.
+func NewMetricFamily(f *Family) (*dto.MetricFamily, error) {
+	t, ok := dto.MetricType_value[f.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q of metric family %q", f.Type, f.Name)
+	}
+	mt := dto.MetricType(t)
+	dtoMF := &dto.MetricFamily{
+		Name:   proto.String(f.Name),
+		Type:   mt.Enum(),
+		Metric: make([]*dto.Metric, len(f.Metrics)),
+	}
+	if f.Help != "" {
+		dtoMF.Help = proto.String(f.Help)
+	}
+	if f.Unit != "" {
+		dtoMF.Unit = proto.String(f.Unit)
+	}
+	for i, item := range f.Metrics {
+		m, err := newDTOMetric(mt, item)
+		if err != nil {
+			return nil, fmt.Errorf("converting metric of metric family %q failed: %w", f.Name, err)
+		}
+		dtoMF.Metric[i] = m
+	}
+	return dtoMF, nil
+}
+
+func newDTOMetric(mt dto.MetricType, item any) (*dto.Metric, error) {
+	var (
+		m   = &dto.Metric{}
+		err error
+	)
+	switch item := item.(type) {
+	case Metric:
+		m.Label = makeDTOLabels(item.Labels)
+		if m.TimestampMs, err = parseTimestamp(item.TimestampMs); err != nil {
+			return nil, err
+		}
+		v, err := parseFloat(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(item.Exemplars) > 0 && (mt != dto.MetricType_COUNTER || len(item.Exemplars) > 1) {
+			return nil, fmt.Errorf("%d exemplars not allowed for type %s", len(item.Exemplars), mt)
+		}
+		if item.CreatedTimestampMs != "" && mt != dto.MetricType_COUNTER {
+			return nil, fmt.Errorf("created timestamp not allowed for type %s", mt)
+		}
+		switch mt {
+		case dto.MetricType_COUNTER:
+			m.Counter = &dto.Counter{Value: &v}
+			if m.Counter.CreatedTimestamp, err = parseTimestampMs(item.CreatedTimestampMs); err != nil {
+				return nil, err
+			}
+			if len(item.Exemplars) == 1 {
+				if m.Counter.Exemplar, err = makeDTOExemplar(item.Exemplars[0]); err != nil {
+					return nil, err
+				}
+			}
+		case dto.MetricType_GAUGE:
+			m.Gauge = &dto.Gauge{Value: &v}
+		case dto.MetricType_UNTYPED:
+			m.Untyped = &dto.Untyped{Value: &v}
+		default:
+			return nil, fmt.Errorf("single value metric not allowed for type %s", mt)
+		}
+	case Summary:
+		if mt != dto.MetricType_SUMMARY {
+			return nil, fmt.Errorf("summary not allowed for type %s", mt)
+		}
+		m.Label = makeDTOLabels(item.Labels)
+		if m.TimestampMs, err = parseTimestamp(item.TimestampMs); err != nil {
+			return nil, err
+		}
+		if m.Summary, err = makeDTOSummary(item); err != nil {
+			return nil, err
+		}
+		if m.Summary.CreatedTimestamp, err = parseTimestampMs(item.CreatedTimestampMs); err != nil {
+			return nil, err
+		}
+	case Histogram:
+		if mt != dto.MetricType_HISTOGRAM && mt != dto.MetricType_GAUGE_HISTOGRAM {
+			return nil, fmt.Errorf("histogram not allowed for type %s", mt)
+		}
+		m.Label = makeDTOLabels(item.Labels)
+		if m.TimestampMs, err = parseTimestamp(item.TimestampMs); err != nil {
+			return nil, err
+		}
+		if m.Histogram, err = makeDTOHistogram(item); err != nil {
+			return nil, err
+		}
+		if m.Histogram.CreatedTimestamp, err = parseTimestampMs(item.CreatedTimestampMs); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected metric of type %T", item)
+	}
+	return m, nil
+}
+
+func makeDTOLabels(labels map[string]string) []*dto.LabelPair {
+	result := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		result = append(result, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	return result
+}
+
+// makeDTOExemplar ignores the Bucket of the Exemplar. It is up to the caller to
+// attach the result to the right bucket.
+func makeDTOExemplar(e Exemplar) (*dto.Exemplar, error) {
+	v, err := parseFloat(e.Value)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := parseTimestampMs(e.TimestampMs)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.Exemplar{Label: makeDTOLabels(e.Labels), Value: &v, Timestamp: ts}, nil
+}
+
+// parseTimestampMs is like parseTimestamp but returns a Timestamp proto
+// message.
+func parseTimestampMs(ts string) (*timestamppb.Timestamp, error) {
+	ms, err := parseTimestamp(ts)
+	if ms == nil || err != nil {
+		return nil, err
+	}
+	return timestamppb.New(time.UnixMilli(*ms)), nil
+}
+
+func parseTimestamp(ts string) (*int64, error) {
+	if ts == "" {
+		return nil, nil
+	}
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return &ms, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %w", err)
+	}
+	return f, nil
+}
+
+func makeDTOSummary(s Summary) (*dto.Summary, error) {
+	count, err := strconv.ParseUint(s.Count, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid count: %w", err)
+	}
+	sum, err := parseFloat(s.Sum)
+	if err != nil {
+		return nil, err
+	}
+	result := &dto.Summary{SampleCount: &count, SampleSum: &sum}
+	for q, v := range s.Quantiles {
+		quantile, err := parseFloat(q)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		result.Quantile = append(result.Quantile, &dto.Quantile{Quantile: &quantile, Value: &value})
+	}
+	sort.Slice(result.Quantile, func(i, j int) bool {
+		return result.Quantile[i].GetQuantile() < result.Quantile[j].GetQuantile()
+	})
+	return result, nil
+}
+
+func makeDTOHistogram(h Histogram) (*dto.Histogram, error) {
+	sum, err := parseFloat(h.Sum)
+	if err != nil {
+		return nil, err
+	}
+	classicBuckets, hasClassic := h.Buckets.(map[string]string)
+	native := h.Buckets
+	if hasClassic {
+		native = h.NativeBuckets
+	}
+	var result *dto.Histogram
+	if h.Raw != nil {
+		// Unlike the Buckets, Raw is exact.
+		result, err = h.Raw.dtoHistogram(h.Count, h.Sum)
+	} else {
+		switch buckets := native.(type) {
+		case nil:
+			result, err = makeDTOClassicHistogram(h.Count, sum, classicBuckets)
+		case [][]any:
+			var nativeBuckets []NativeBucket
+			if nativeBuckets, err = newNativeBuckets(buckets); err == nil {
+				result, err = makeDTONativeHistogram(h.Count, sum, nativeBuckets)
+			}
+		case []NativeBucket:
+			result, err = makeDTONativeHistogram(h.Count, sum, buckets)
+		default:
+			return nil, fmt.Errorf("unexpected buckets of type %T", native)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	isNative := len(result.GetNegativeSpan())+len(result.GetPositiveSpan()) > 0
+	if !isNative || !hasClassic {
+		if err := addDTOExemplars(result, h.Exemplars, isNative); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	// A histogram with both classic buckets and native spans.
+	classic, err := makeDTOClassicHistogram(h.Count, sum, classicBuckets)
+	if err != nil {
+		return nil, err
+	}
+	result.Bucket = classic.Bucket
+	if err := addDTOExemplars(result, h.Exemplars, false); err != nil {
+		return nil, err
+	}
+	if err := addDTOExemplars(result, h.NativeExemplars, true); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// addDTOExemplars adds the exemplars of a native histogram to the histogram
+// itself and the exemplars of a classic histogram to the buckets they belong
+// to.
+func addDTOExemplars(h *dto.Histogram, exemplars []Exemplar, native bool) error {
+	for _, e := range exemplars {
+		dtoE, err := makeDTOExemplar(e)
+		if err != nil {
+			return err
+		}
+		if native {
+			h.Exemplars = append(h.Exemplars, dtoE)
+			continue
+		}
+		upperBound, err := parseFloat(e.Bucket)
+		if err != nil {
+			return fmt.Errorf("invalid exemplar bucket: %w", err)
+		}
+		found := false
+		for _, b := range h.Bucket {
+			if b.GetUpperBound() == upperBound {
+				b.Exemplar, found = dtoE, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no bucket with upper bound %s for exemplar", e.Bucket)
+		}
+	}
+	return nil
+}
+
+func makeDTOClassicHistogram(count string, sum float64, buckets map[string]string) (*dto.Histogram, error) {
+	result := &dto.Histogram{SampleSum: &sum}
+	if c, err := strconv.ParseUint(count, 10, 64); err == nil {
+		result.SampleCount = &c
+	} else {
+		c, err := parseFloat(count)
+		if err != nil {
+			return nil, err
+		}
+		result.SampleCountFloat = &c
+	}
+	for ub, c := range buckets {
+		upperBound, err := parseFloat(ub)
+		if err != nil {
+			return nil, err
+		}
+		b := &dto.Bucket{UpperBound: &upperBound}
+		if result.SampleCountFloat == nil {
+			cumulativeCount, err := strconv.ParseUint(c, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bucket count: %w", err)
+			}
+			b.CumulativeCount = &cumulativeCount
+		} else {
+			cumulativeCount, err := parseFloat(c)
+			if err != nil {
+				return nil, err
+			}
+			b.CumulativeCountFloat = &cumulativeCount
+		}
+		result.Bucket = append(result.Bucket, b)
+	}
+	sort.Slice(result.Bucket, func(i, j int) bool {
+		return result.Bucket[i].GetUpperBound() < result.Bucket[j].GetUpperBound()
+	})
+	return result, nil
+}
+
+// newNativeBuckets converts native buckets as created by
+// histogram.BucketsAsJson.
+func newNativeBuckets(buckets [][]any) ([]NativeBucket, error) {
+	result := make([]NativeBucket, len(buckets))
+	for i, b := range buckets {
+		if len(b) != 4 {
+			return nil, fmt.Errorf("native bucket has %d elements, expected 4", len(b))
+		}
+		boundaries, ok := b[0].(uint64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected native bucket boundaries of type %T", b[0])
+		}
+		result[i].Boundaries = boundaries
+		for j, s := range []*string{&result[i].Lower, &result[i].Upper, &result[i].Count} {
+			if *s, ok = b[j+1].(string); !ok {
+				return nil, fmt.Errorf("unexpected native bucket element of type %T", b[j+1])
+			}
+		}
+	}
+	return result, nil
+}
+
+func parseNativeBuckets(buckets []NativeBucket) ([]histogram.APIBucket[float64], error) {
+	result := make([]histogram.APIBucket[float64], len(buckets))
+	for i, b := range buckets {
+		var bounds [3]float64
+		for j, s := range []string{b.Lower, b.Upper, b.Count} {
+			f, err := parseFloat(s)
+			if err != nil {
+				return nil, err
+			}
+			bounds[j] = f
+		}
+		result[i] = histogram.APIBucket[float64]{Boundaries: b.Boundaries, Lower: bounds[0], Upper: bounds[1], Count: bounds[2]}
+	}
+	return result, nil
+}
+
+// makeDTONativeHistogram creates an integer histogram if all counts are
+// integers and a float histogram otherwise.
+func makeDTONativeHistogram(count string, sum float64, nativeBuckets []NativeBucket) (*dto.Histogram, error) {
+	buckets, err := parseNativeBuckets(nativeBuckets)
+	if err != nil {
+		return nil, err
+	}
+	intCount, err := strconv.ParseUint(count, 10, 64)
+	isInt := err == nil
+	intBuckets := make([]histogram.APIBucket[uint64], len(buckets))
+	for i, b := range buckets {
+		if b.Count != float64(uint64(b.Count)) {
+			isInt = false
+			break
+		}
+		intBuckets[i] = histogram.APIBucket[uint64]{Lower: b.Lower, Upper: b.Upper, Count: uint64(b.Count)}
+	}
+	if isInt {
+		h, err := histogram.FromAPIBuckets(intBuckets)
+		if err != nil {
+			return nil, err
+		}
+		h.Count, h.Sum = intCount, sum
+		return histogram.NewDTOHistogram(h, nil), nil
+	}
+	floatCount, err := parseFloat(count)
+	if err != nil {
+		return nil, err
+	}
+	fh, err := histogram.FromAPIFloatBuckets(buckets)
+	if err != nil {
+		return nil, err
+	}
+	fh.Count, fh.Sum = floatCount, sum
+	return histogram.NewDTOHistogram(nil, fh), nil
+}
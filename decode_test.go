@@ -0,0 +1,194 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestConvertFromFamily(t *testing.T) {
+	for _, tc := range tcs {
+		mf, err := NewMetricFamily(tc.output)
+		if err != nil {
+			t.Errorf("test case %s: conversion to MetricFamily failed: %v", tc.name, err)
+			continue
+		}
+		output := NewFamily(mf)
+		if !reflect.DeepEqual(tc.output, output) {
+			t.Errorf("test case %s: round trip via MetricFamily failed:\nexpected:\n%s\n\nactual:\n%s",
+				tc.name, spew.Sdump(tc.output), spew.Sdump(output))
+		}
+	}
+}
+
+func TestConvertFromFamilyNativeHistogramLayout(t *testing.T) {
+	for name, h := range map[string]*dto.Histogram{
+		"integer histogram with zero and negative buckets": {
+			SampleCount:   uintPtr(12),
+			SampleSum:     floatPtr(-3.5),
+			Schema:        int32Ptr(2),
+			ZeroThreshold: floatPtr(0.001),
+			ZeroCount:     uintPtr(2),
+			NegativeSpan: []*dto.BucketSpan{
+				createBucketSpan(-2, 2),
+				createBucketSpan(3, 1),
+			},
+			NegativeDelta: []int64{1, 1, -1},
+			PositiveSpan: []*dto.BucketSpan{
+				createBucketSpan(5, 1),
+			},
+			PositiveDelta: []int64{5},
+		},
+		"float histogram with negative schema": {
+			SampleCountFloat: floatPtr(4.5),
+			SampleSum:        floatPtr(100),
+			Schema:           int32Ptr(-2),
+			PositiveSpan: []*dto.BucketSpan{
+				createBucketSpan(1, 2),
+			},
+			PositiveCount: []float64{1.5, 3},
+		},
+		// A zero threshold of 0 results in a zero bucket of [-0,0].
+		"integer histogram with zero threshold 0": {
+			SampleCount:   uintPtr(4),
+			SampleSum:     floatPtr(3),
+			Schema:        int32Ptr(1),
+			ZeroThreshold: floatPtr(0),
+			ZeroCount:     uintPtr(1),
+			PositiveSpan: []*dto.BucketSpan{
+				createBucketSpan(0, 2),
+			},
+			PositiveDelta: []int64{2, -1},
+		},
+		// The schema of a histogram without populated buckets cannot
+		// be restored and is therefore left at 0 here.
+		"empty histogram": {
+			SampleCount:  uintPtr(0),
+			SampleSum:    floatPtr(0),
+			PositiveSpan: []*dto.BucketSpan{createBucketSpan(0, 0)},
+		},
+	} {
+		expected := NewFamily(&dto.MetricFamily{
+			Name:   strPtr("histogram"),
+			Type:   metricTypePtr(dto.MetricType_HISTOGRAM),
+			Metric: []*dto.Metric{{Histogram: h}},
+		})
+		mf, err := NewMetricFamily(expected)
+		if err != nil {
+			t.Errorf("%s: conversion to MetricFamily failed: %v", name, err)
+			continue
+		}
+		if got, want := mf.Metric[0].GetHistogram().GetSchema(), h.GetSchema(); got != want {
+			t.Errorf("%s: expected schema %d, got %d", name, want, got)
+		}
+		output := NewFamily(mf)
+		if !reflect.DeepEqual(expected, output) {
+			t.Errorf("%s: round trip via MetricFamily failed:\nexpected:\n%s\n\nactual:\n%s",
+				name, spew.Sdump(expected), spew.Sdump(output))
+		}
+	}
+}
+
+func TestUnmarshalFamily(t *testing.T) {
+	for _, tc := range tcs {
+		jsonText, err := json.Marshal(tc.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var output Family
+		if err := json.Unmarshal(jsonText, &output); err != nil {
+			t.Errorf("test case %s: unmarshaling failed: %v", tc.name, err)
+			continue
+		}
+		// NewFamily creates native buckets as [][]any while unmarshaling
+		// creates the typed []NativeBucket.
+		expected := *tc.output
+		expected.Metrics = make([]any, len(tc.output.Metrics))
+		for i, m := range tc.output.Metrics {
+			if h, ok := m.(Histogram); ok {
+				if buckets, ok := h.Buckets.([][]any); ok {
+					if h.Buckets, err = newNativeBuckets(buckets); err != nil {
+						t.Fatal(err)
+					}
+				}
+				m = h
+			}
+			expected.Metrics[i] = m
+		}
+		if !reflect.DeepEqual(&expected, &output) {
+			t.Errorf("test case %s: unmarshaling failed:\nexpected:\n%s\n\nactual:\n%s",
+				tc.name, spew.Sdump(&expected), spew.Sdump(&output))
+		}
+		// The typed buckets have to marshal into the same JSON.
+		reencoded, err := json.Marshal(&output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(jsonText, reencoded) {
+			t.Errorf("test case %s: re-encoding failed:\nexpected:\n%s\n\nactual:\n%s", tc.name, jsonText, reencoded)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	families := make([]*Family, len(tcs))
+	for i, tc := range tcs {
+		families[i] = tc.output
+	}
+	jsonText, err := json.Marshal(families)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfChan := make(chan *dto.MetricFamily, len(tcs))
+	if err := ParseJSON(bytes.NewReader(jsonText), mfChan); err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	for mf := range mfChan {
+		output := NewFamily(mf)
+		if !reflect.DeepEqual(tcs[i].output, output) {
+			t.Errorf("test case %s: round trip via JSON failed:\nexpected:\n%s\n\nactual:\n%s",
+				tcs[i].name, spew.Sdump(tcs[i].output), spew.Sdump(output))
+		}
+		i++
+	}
+	if i != len(tcs) {
+		t.Errorf("expected %d metric families, got %d", len(tcs), i)
+	}
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"invalid JSON":    `[{"name":`,
+		"unknown type":    `[{"name":"a","type":"FOO","metrics":[{"value":"1"}]}]`,
+		"invalid value":   `[{"name":"a","type":"GAUGE","metrics":[{"value":"one"}]}]`,
+		"invalid count":   `[{"name":"a","type":"SUMMARY","metrics":[{"count":"-1","sum":"0"}]}]`,
+		"invalid buckets": `[{"name":"a","type":"HISTOGRAM","metrics":[{"buckets":[[0,"1"]],"count":"1","sum":"0"}]}]`,
+		"gauge created":   `[{"name":"a","type":"GAUGE","metrics":[{"value":"1","created_timestamp_ms":"1"}]}]`,
+		"gauge exemplar":  `[{"name":"a","type":"GAUGE","metrics":[{"value":"1","exemplars":[{"value":"1"}]}]}]`,
+		"unknown bucket":  `[{"name":"a","type":"HISTOGRAM","metrics":[{"buckets":{"1":"1"},"count":"1","sum":"0","exemplars":[{"value":"1","bucket":"2"}]}]}]`,
+	} {
+		mfChan := make(chan *dto.MetricFamily, 1)
+		if err := ParseJSON(bytes.NewReader([]byte(in)), mfChan); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
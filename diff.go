@@ -0,0 +1,261 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// Statuses of a FamilyDiff or SeriesDiff.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// Diff describes the differences between two sets of Families, e.g. from two
+// scrapes of the same target.
+type Diff struct {
+	// Families contains the metric families that differ, keyed by name.
+	Families map[string]*FamilyDiff `json:"families"`
+}
+
+// FamilyDiff describes how a metric family differs.
+type FamilyDiff struct {
+	// Status is DiffAdded, DiffRemoved, or DiffChanged.
+	Status string  `json:"status"`
+	Type   *Change `json:"type,omitempty"`
+	Help   *Change `json:"help,omitempty"`
+	Unit   *Change `json:"unit,omitempty"`
+	// Series contains the series that differ, keyed by their label set in
+	// the form `{name="value", ...}`. Only set for changed families.
+	Series map[string]*SeriesDiff `json:"series,omitempty"`
+}
+
+// SeriesDiff describes how a series differs.
+type SeriesDiff struct {
+	// Status is DiffAdded, DiffRemoved, or DiffChanged.
+	Status string            `json:"status"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Values contains the values that differ, keyed by what they are:
+	// "value" for counters, gauges, and untyped metrics, "count", "sum",
+	// and e.g. "quantile 0.99" for summaries, and "count", "sum", and e.g.
+	// "bucket 0.5" (classic) or "bucket (0.5,1]" (native) for histograms.
+	// Only set for changed series.
+	Values map[string]*Change `json:"values,omitempty"`
+}
+
+// Change is a changed value, which is empty where it does not exist. Delta is
+// New minus Old if both are finite numbers.
+type Change struct {
+	Old   string `json:"old"`
+	New   string `json:"new"`
+	Delta string `json:"delta,omitempty"`
+}
+
+// DiffFamilies compares the Families in a with those in b and returns the
+// differences of b relative to a. Families with the same name are treated as
+// one. Timestamps, created timestamps, and exemplars are not compared, as they
+// usually change between scrapes anyway.
+func DiffFamilies(a, b []*Family) *Diff {
+	familiesA, familiesB := familiesByName(a), familiesByName(b)
+	d := &Diff{Families: map[string]*FamilyDiff{}}
+	for name, fa := range familiesA {
+		fb, ok := familiesB[name]
+		if !ok {
+			d.Families[name] = &FamilyDiff{Status: DiffRemoved}
+			continue
+		}
+		fd := &FamilyDiff{
+			Status: DiffChanged,
+			Type:   newChange(fa.Type, fb.Type),
+			Help:   newChange(fa.Help, fb.Help),
+			Unit:   newChange(fa.Unit, fb.Unit),
+			Series: diffSeries(fa.Metrics, fb.Metrics),
+		}
+		if fd.Type != nil || fd.Help != nil || fd.Unit != nil || len(fd.Series) > 0 {
+			d.Families[name] = fd
+		}
+	}
+	for name := range familiesB {
+		if _, ok := familiesA[name]; !ok {
+			d.Families[name] = &FamilyDiff{Status: DiffAdded}
+		}
+	}
+	return d
+}
+
+// WriteSummary writes a human-readable summary of the Diff to w, with one line
+// per added ("+"), removed ("-"), or changed ("~") metric family, series, or
+// value.
+func (d *Diff) WriteSummary(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	counts := map[string]int{}
+	for _, name := range slices.Sorted(maps.Keys(d.Families)) {
+		fd := d.Families[name]
+		counts[fd.Status]++
+		fmt.Fprintf(bw, "%s %s\n", statusSymbol(fd.Status), name)
+		for _, c := range []struct {
+			what   string
+			change *Change
+		}{{"type", fd.Type}, {"help", fd.Help}, {"unit", fd.Unit}} {
+			if c.change != nil {
+				fmt.Fprintf(bw, "    %s: %q -> %q\n", c.what, c.change.Old, c.change.New)
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(fd.Series)) {
+			sd := fd.Series[key]
+			fmt.Fprintf(bw, "    %s %s\n", statusSymbol(sd.Status), key)
+			for _, what := range slices.Sorted(maps.Keys(sd.Values)) {
+				fmt.Fprintf(bw, "        %s: %s\n", what, sd.Values[what])
+			}
+		}
+	}
+	fmt.Fprintf(bw, "%d metric families added, %d removed, %d changed.\n",
+		counts[DiffAdded], counts[DiffRemoved], counts[DiffChanged])
+	return bw.Flush()
+}
+
+// String returns the Change as "old -> new (delta)", with "absent" for a value
+// that does not exist.
+func (c *Change) String() string {
+	o, n := cmp.Or(c.Old, "absent"), cmp.Or(c.New, "absent")
+	if c.Delta == "" {
+		return fmt.Sprintf("%s -> %s", o, n)
+	}
+	return fmt.Sprintf("%s -> %s (%s)", o, n, c.Delta)
+}
+
+func statusSymbol(status string) string {
+	switch status {
+	case DiffAdded:
+		return "+"
+	case DiffRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// familiesByName merges the provided Families by name.
+func familiesByName(families []*Family) map[string]*Family {
+	result := map[string]*Family{}
+	for _, f := range families {
+		if existing, ok := result[f.Name]; ok {
+			merged := *existing
+			merged.Metrics = append(slices.Clip(existing.Metrics), f.Metrics...)
+			result[f.Name] = &merged
+			continue
+		}
+		result[f.Name] = f
+	}
+	return result
+}
+
+func diffSeries(a, b []any) map[string]*SeriesDiff {
+	seriesA, seriesB := seriesByLabels(a), seriesByLabels(b)
+	result := map[string]*SeriesDiff{}
+	for key, sa := range seriesA {
+		sb, ok := seriesB[key]
+		if !ok {
+			result[key] = &SeriesDiff{Status: DiffRemoved, Labels: sa.labels}
+			continue
+		}
+		values := map[string]*Change{}
+		for what, va := range sa.values {
+			if c := newChange(va, sb.values[what]); c != nil {
+				values[what] = c
+			}
+		}
+		for what, vb := range sb.values {
+			if _, ok := sa.values[what]; !ok {
+				values[what] = newChange("", vb)
+			}
+		}
+		if len(values) > 0 {
+			result[key] = &SeriesDiff{Status: DiffChanged, Labels: sa.labels, Values: values}
+		}
+	}
+	for key, sb := range seriesB {
+		if _, ok := seriesA[key]; !ok {
+			result[key] = &SeriesDiff{Status: DiffAdded, Labels: sb.labels}
+		}
+	}
+	return result
+}
+
+type series struct {
+	labels map[string]string
+	values map[string]string
+}
+
+// seriesByLabels returns the series among the provided Metrics, Summaries, and
+// Histograms, keyed by their label set.
+func seriesByLabels(items []any) map[string]series {
+	result := map[string]series{}
+	for _, item := range items {
+		var s series
+		switch m := item.(type) {
+		case Metric:
+			s = series{labels: m.Labels, values: map[string]string{"value": m.Value}}
+		case Summary:
+			s = series{labels: m.Labels, values: map[string]string{"count": m.Count, "sum": m.Sum}}
+			for q, v := range m.Quantiles {
+				s.values["quantile "+q] = v
+			}
+		case Histogram:
+			s = series{labels: m.Labels, values: map[string]string{"count": m.Count, "sum": m.Sum}}
+			if buckets, ok := m.Buckets.(map[string]string); ok {
+				for ub, c := range buckets {
+					s.values["bucket "+ub] = c
+				}
+			}
+			nativeBuckets, _ := m.nativeBuckets()
+			for _, b := range nativeBuckets {
+				s.values["bucket "+b.interval()] = b.Count
+			}
+		default:
+			continue
+		}
+		result[labels.FromMap(s.labels).String()] = s
+	}
+	return result
+}
+
+// newChange returns a Change from a to b, or nil if they are the same.
+func newChange(a, b string) *Change {
+	if a == b {
+		return nil
+	}
+	c := &Change{Old: a, New: b}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil && !math.IsInf(fa, 0) && !math.IsInf(fb, 0) && !math.IsNaN(fa) && !math.IsNaN(fb) {
+		delta := fb - fa
+		c.Delta = fmt.Sprint(delta)
+		if delta > 0 {
+			c.Delta = "+" + c.Delta
+		}
+	}
+	return c
+}
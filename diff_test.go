@@ -0,0 +1,137 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestDiffFamilies(t *testing.T) {
+	a := []*Family{
+		{
+			Name: "a_total",
+			Help: "A counter.",
+			Type: "COUNTER",
+			Metrics: []any{
+				Metric{Labels: map[string]string{"x": "1"}, TimestampMs: "1", Value: "3"},
+				Metric{Labels: map[string]string{"x": "2"}, Value: "5"},
+			},
+		},
+		{Name: "gone", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "1"}}},
+		{
+			Name: "c",
+			Type: "HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels:  map[string]string{},
+					Buckets: [][]any{{uint64(0), "1", "2", "3"}, {uint64(3), "2", "4", "1"}},
+					Count:   "4",
+					Sum:     "6",
+				},
+			},
+		},
+		{Name: "same", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "NaN"}}},
+	}
+	b := []*Family{
+		{
+			Name: "a_total",
+			Help: "A counter.",
+			Type: "COUNTER",
+			Metrics: []any{
+				Metric{Labels: map[string]string{"x": "1"}, TimestampMs: "2", Value: "7"},
+			},
+		},
+		{
+			Name: "a_total",
+			Help: "A counter.",
+			Type: "COUNTER",
+			Metrics: []any{
+				Metric{Labels: map[string]string{"x": "3"}, Value: "1"},
+			},
+		},
+		{Name: "new", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "1"}}},
+		{
+			Name: "c",
+			Type: "HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels:  map[string]string{},
+					Buckets: []NativeBucket{{Boundaries: 0, Lower: "1", Upper: "2", Count: "2.5"}},
+					Count:   "+Inf",
+					Sum:     "6",
+				},
+			},
+		},
+		{Name: "same", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "NaN"}}},
+	}
+	expected := &Diff{Families: map[string]*FamilyDiff{
+		"a_total": {
+			Status: DiffChanged,
+			Series: map[string]*SeriesDiff{
+				`{x="1"}`: {
+					Status: DiffChanged,
+					Labels: map[string]string{"x": "1"},
+					Values: map[string]*Change{"value": {Old: "3", New: "7", Delta: "+4"}},
+				},
+				`{x="2"}`: {Status: DiffRemoved, Labels: map[string]string{"x": "2"}},
+				`{x="3"}`: {Status: DiffAdded, Labels: map[string]string{"x": "3"}},
+			},
+		},
+		"c": {
+			Status: DiffChanged,
+			Series: map[string]*SeriesDiff{
+				"{}": {
+					Status: DiffChanged,
+					Labels: map[string]string{},
+					Values: map[string]*Change{
+						"bucket (1,2]": {Old: "3", New: "2.5", Delta: "-0.5"},
+						"bucket [2,4]": {Old: "1", New: ""},
+						"count":        {Old: "4", New: "+Inf"},
+					},
+				},
+			},
+		},
+		"gone": {Status: DiffRemoved},
+		"new":  {Status: DiffAdded},
+	}}
+	if got := DiffFamilies(a, b); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected\n%s\ngot\n%s", spew.Sdump(expected), spew.Sdump(got))
+	}
+
+	var buf bytes.Buffer
+	if err := DiffFamilies(a, b).WriteSummary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expectedSummary := `~ a_total
+    ~ {x="1"}
+        value: 3 -> 7 (+4)
+    - {x="2"}
+    + {x="3"}
+~ c
+    ~ {}
+        bucket (1,2]: 3 -> 2.5 (-0.5)
+        bucket [2,4]: 1 -> absent
+        count: 4 -> +Inf
+- gone
++ new
+1 metric families added, 1 removed, 2 changed.
+`
+	if got := buf.String(); got != expectedSummary {
+		t.Errorf("expected summary\n%s\ngot\n%s", expectedSummary, got)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"encoding/json"
+	"io"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Encoder writes MetricFamilies as newline-delimited JSON (NDJSON), i.e. one
+// Family per line, as soon as they are encoded. Unlike marshaling a slice of
+// all Families at once, it does not need to keep the whole scrape in memory.
+type Encoder struct {
+	enc       *json.Encoder
+	perSeries bool
+
+	nativeNumbers bool
+	nonFinite     json.RawMessage
+	flatten       bool
+
+	quantiles []float64
+	mean      bool
+
+	options FamilyOptions
+}
+
+// NewEncoder returns an Encoder writing to w. If perSeries is true, each line
+// contains a Family with exactly one of the Metrics of the encoded
+// MetricFamily, so that even a single huge MetricFamily is written in many
+// small lines.
+func NewEncoder(w io.Writer, perSeries bool) *Encoder {
+	return &Encoder{enc: json.NewEncoder(w), perSeries: perSeries}
+}
+
+// SetNativeNumbers makes the Encoder write numbers as JSON numbers rather than
+// strings, see NativeNumbers for details.
+func (e *Encoder) SetNativeNumbers(nonFinite json.RawMessage) {
+	e.nativeNumbers = true
+	e.nonFinite = nonFinite
+}
+
+// SetFlatten makes the Encoder write one Sample per line, as created by
+// Flatten, rather than Families.
+func (e *Encoder) SetFlatten() {
+	e.flatten = true
+}
+
+// SetHistogramStats makes the Encoder add the provided quantile estimates and,
+// if mean is true, the mean to histograms, see Family.AddHistogramStats.
+func (e *Encoder) SetHistogramStats(quantiles []float64, mean bool) {
+	e.quantiles = quantiles
+	e.mean = mean
+}
+
+// SetNativeHistogramFormat makes the Encoder represent native histograms in the
+// provided format, see NewFamilyWithOptions.
+func (e *Encoder) SetNativeHistogramFormat(format NativeHistogramFormat) {
+	e.options.NativeFormat = format
+}
+
+// SetHistogramBuckets makes the Encoder keep the provided buckets of histograms
+// with both classic buckets and native spans, see NewFamilyWithOptions.
+func (e *Encoder) SetHistogramBuckets(buckets HistogramBuckets) {
+	e.options.HistogramBuckets = buckets
+}
+
+// Encode converts the provided MetricFamily with NewFamilyWithOptions and
+// writes the result as one line or, if the Encoder works per series, as one
+// line per Metric. If the Encoder flattens, it writes one line per Sample
+// instead. If histogram stats cannot be added to some histograms, Encode still
+// writes the result and then returns the error of AddHistogramStats.
+func (e *Encoder) Encode(mf *dto.MetricFamily) error {
+	f := NewFamilyWithOptions(mf, e.options)
+	var statsErr error
+	if len(e.quantiles) > 0 || e.mean {
+		statsErr = f.AddHistogramStats(e.quantiles, e.mean)
+	}
+	if err := e.write(f); err != nil {
+		return err
+	}
+	return statsErr
+}
+
+func (e *Encoder) write(f *Family) error {
+	if e.flatten {
+		for _, s := range Flatten(f) {
+			var err error
+			if e.nativeNumbers {
+				err = e.enc.Encode(NativeSample{Sample: s, NonFinite: e.nonFinite})
+			} else {
+				err = e.enc.Encode(s)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !e.perSeries {
+		return e.encode(f)
+	}
+	for _, m := range f.Metrics {
+		series := *f
+		series.Metrics = []any{m}
+		if err := e.encode(&series); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encode(f *Family) error {
+	if e.nativeNumbers {
+		return e.enc.Encode(NativeNumbers{Family: f, NonFinite: e.nonFinite})
+	}
+	return e.enc.Encode(f)
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	var expected, expectedPerSeries bytes.Buffer
+	for _, tc := range tcs {
+		line, err := json.Marshal(tc.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected.Write(line)
+		expected.WriteByte('\n')
+		for _, m := range tc.output.Metrics {
+			series := *tc.output
+			series.Metrics = []any{m}
+			line, err := json.Marshal(series)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedPerSeries.Write(line)
+			expectedPerSeries.WriteByte('\n')
+		}
+	}
+
+	for perSeries, want := range map[bool]*bytes.Buffer{false: &expected, true: &expectedPerSeries} {
+		var out bytes.Buffer
+		enc := NewEncoder(&out, perSeries)
+		for _, tc := range tcs {
+			if err := enc.Encode(tc.mFamily); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(want.Bytes(), out.Bytes()) {
+			t.Errorf("perSeries=%t: unexpected output:\nexpected:\n%s\n\nactual:\n%s", perSeries, want, &out)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// scrapeTimeoutHeader tells the target how long it has got to respond.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// ErrBodySizeLimit is returned by Fetcher.Fetch if the response body is larger
+// than the configured limit.
+var ErrBodySizeLimit = errors.New("body size limit exceeded")
+
+// Format is an exposition format that can be requested from a target. The
+// values are the same as those of the scrape protocols in the Prometheus
+// configuration.
+type Format string
+
+// The supported formats.
+const (
+	FormatProtobuf     Format = "PrometheusProto"
+	FormatOpenMetrics1 Format = "OpenMetricsText1.0.0"
+	FormatOpenMetrics0 Format = "OpenMetricsText0.0.1"
+	FormatText1        Format = "PrometheusText1.0.0"
+	FormatText0        Format = "PrometheusText0.0.4"
+)
+
+var formatMediaTypes = map[Format]string{
+	FormatProtobuf:     "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited",
+	FormatOpenMetrics1: "application/openmetrics-text;version=1.0.0",
+	FormatOpenMetrics0: "application/openmetrics-text;version=0.0.1",
+	FormatText1:        "text/plain;version=1.0.0",
+	FormatText0:        "text/plain;version=0.0.4",
+}
+
+// DefaultFormats are the formats accepted by a Fetcher without Formats, in
+// order of preference.
+var DefaultFormats = []Format{FormatProtobuf, FormatOpenMetrics1, FormatOpenMetrics0, FormatText1, FormatText0}
+
+// Fetcher retrieves metrics via HTTP. The zero value is ready to use and
+// behaves like FetchMetricFamilies. A Fetcher can be used concurrently, as long
+// as its fields are not changed anymore.
+type Fetcher struct {
+	// Client is used to send the requests. If nil, a Client with Transport
+	// is used.
+	Client *http.Client
+	// Transport is used to send the requests if Client is nil. If nil, a
+	// clone of http.DefaultTransport is used that dials with DialContext.
+	// Otherwise, the Transport has to dial with DialContext to support Unix
+	// domain sockets.
+	Transport http.RoundTripper
+	// Method is the HTTP method of the requests. If empty, POST is used if
+	// Body is not nil, and GET otherwise.
+	Method string
+	// If Body is not nil, it is sent as the body of each request, with the
+	// Content-Type "application/x-www-form-urlencoded" unless set in Header.
+	Body []byte
+	// Header contains additional headers to send with each request. They
+	// replace the headers set by the Fetcher itself, like Accept. A Host
+	// header replaces the host of the URL in the request.
+	Header http.Header
+	// If Username is not empty, HTTP basic authentication is used with
+	// Username and Password.
+	Username, Password string
+	// If BearerToken is not empty, it is sent in the Authorization header.
+	// It cannot be combined with Username.
+	BearerToken string
+	// If EscapingScheme is not empty, it is added as the value of the
+	// 'escaping' parameter to each format in the Accept header.
+	EscapingScheme string
+	// Formats are the accepted formats in order of preference. If empty,
+	// DefaultFormats are accepted.
+	Formats []Format
+	// If BodySizeLimit is positive, reading a response body of more than
+	// BodySizeLimit bytes fails with ErrBodySizeLimit.
+	BodySizeLimit int64
+	// If UserAgent is not empty, it is sent in the User-Agent header.
+	UserAgent string
+	// If ValidationScheme is set, metric and label names are validated
+	// according to it. Otherwise, names are only validated as far as the
+	// parser of the received format requires it.
+	ValidationScheme model.ValidationScheme
+}
+
+// Fetch retrieves metrics from the provided URL, decodes them into MetricFamily
+// proto messages, and sends them to the provided channel. It returns after all
+// MetricFamilies have been sent and closes the channel in any case.
+//
+// The URL may also refer to a Unix domain socket as
+// "unix:///path/to.sock:/metrics", i.e. the path of the socket (which must not
+// contain a colon) followed by a colon and the path to request via the socket.
+// If the latter is omitted, "/metrics" is requested. Such requests are sent to
+// "localhost:0", which DialContext connects to the socket, with the Host
+// "localhost" unless set in Header.
+//
+// Fetch gives up once the provided Context is canceled or its deadline is
+// exceeded, be it while sending the request, while reading and parsing the
+// response, or while waiting to send MetricFamilies to the channel. If the
+// Context has a deadline, the time left until then is sent in the
+// X-Prometheus-Scrape-Timeout-Seconds header, as the Prometheus server does,
+// so that the target can adjust to it.
+func (f *Fetcher) Fetch(ctx context.Context, url string, ch chan<- *dto.MetricFamily) error {
+	reqURL := url
+	socket, path, isUnix := splitUnixURL(url)
+	if isUnix {
+		ctx = context.WithValue(ctx, unixSocketKey{}, socket)
+		reqURL = unixSocketHost + path
+	}
+	req, err := f.newRequest(ctx, reqURL)
+	if err != nil {
+		close(ch)
+		return err
+	}
+	if isUnix {
+		if f.Header.Get("Host") == "" {
+			req.Host = "localhost"
+		}
+		// Never reuse connections, as they all have the same address,
+		// no matter which socket they are connected to.
+		req.Close = true
+	}
+	client := f.Client
+	if client == nil {
+		transport := f.Transport
+		if transport == nil {
+			transport = defaultTransport
+		}
+		client = &http.Client{Transport: transport}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		close(ch)
+		return fmt.Errorf("executing %s request for URL %q failed: %w", req.Method, url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		close(ch)
+		return fmt.Errorf("%s request for URL %q returned HTTP status %s", req.Method, url, resp.Status)
+	}
+	var body io.Reader = resp.Body
+	if f.BodySizeLimit > 0 {
+		body = &limitedReader{r: body, limit: f.BodySizeLimit}
+	}
+	return parseResponse(resp.Header.Get("Content-Type"), body, sink{ctx: ctx, ch: ch, scheme: f.ValidationScheme})
+}
+
+func (f *Fetcher) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	if f.Username != "" && f.BearerToken != "" {
+		return nil, errors.New("basic authentication and bearer token are mutually exclusive")
+	}
+	accept, err := f.acceptHeader()
+	if err != nil {
+		return nil, err
+	}
+	method := f.Method
+	switch {
+	case method != "":
+	case f.Body != nil:
+		method = http.MethodPost
+	default:
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if f.Body != nil {
+		body = bytes.NewReader(f.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s request for URL %q failed: %w", method, url, err)
+	}
+	req.Header.Set("Accept", accept)
+	if f.Body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(scrapeTimeoutHeader, strconv.FormatFloat(time.Until(deadline).Seconds(), 'f', -1, 64))
+	}
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	if f.Username != "" {
+		req.SetBasicAuth(f.Username, f.Password)
+	}
+	if f.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.BearerToken)
+	}
+	for name, values := range f.Header {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	// The Host header is ignored by the http.Client, which sends req.Host
+	// instead.
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+	return req, nil
+}
+
+// unixSocketKey is the key for the path of the Unix domain socket to connect to
+// in the Context of a request.
+type unixSocketKey struct{}
+
+// unixSocketHost is the URL prefix for requests via a Unix domain socket. No
+// ordinary target has port 0, and Go never sends requests to localhost via the
+// proxy configured in the environment.
+const unixSocketHost = "http://localhost:0"
+
+var (
+	dialer = &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	defaultTransport = func() *http.Transport {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = DialContext
+		return t
+	}()
+)
+
+// DialContext connects to the Unix domain socket of a request for a target like
+// "unix:///path/to.sock:/metrics" (see Fetcher.Fetch), and to the provided
+// address otherwise. It can be used as the DialContext of an http.Transport
+// (or with config.WithDialContextFunc from github.com/prometheus/common/config)
+// to support such targets with a custom Fetcher.Transport or Fetcher.Client.
+func DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if socket, ok := ctx.Value(unixSocketKey{}).(string); ok {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// splitUnixURL splits a URL like "unix:///path/to.sock:/metrics" into the path
+// of the socket and the path to request. ok is false if url does not refer to
+// a Unix domain socket.
+func splitUnixURL(url string) (socket, path string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "unix://")
+	if !ok {
+		return "", "", false
+	}
+	socket, path, found := strings.Cut(rest, ":")
+	if !found {
+		path = "/metrics"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return socket, path, true
+}
+
+// acceptHeader returns the Accept header for the configured formats, weighted
+// in order of preference like the Prometheus server does it.
+func (f *Fetcher) acceptHeader() (string, error) {
+	formats := f.Formats
+	if len(formats) == 0 {
+		formats = DefaultFormats
+	}
+	vals := make([]string, 0, len(formats))
+	seen := map[Format]struct{}{}
+	weight := len(formatMediaTypes) + 1
+	for _, format := range formats {
+		val, ok := formatMediaTypes[format]
+		if !ok {
+			return "", fmt.Errorf("unknown format %q", format)
+		}
+		if _, ok := seen[format]; ok {
+			return "", fmt.Errorf("duplicate format %q", format)
+		}
+		seen[format] = struct{}{}
+		// Note that we even add the escaping parameter to
+		// text/plain;version=0.0.4. This version officially does not
+		// support escaping scheme selection, but some targets implement
+		// it anyway, so no harm in trying.
+		if f.EscapingScheme != "" {
+			val += ";escaping=" + f.EscapingScheme
+		}
+		vals = append(vals, fmt.Sprintf("%s;q=0.%d", val, weight))
+		weight--
+	}
+	return strings.Join(vals, ","), nil
+}
+
+// limitedReader reads from r, but fails with ErrBodySizeLimit once more than
+// limit bytes have been read.
+type limitedReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrBodySizeLimit, l.limit)
+	}
+	return n, err
+}
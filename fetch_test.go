@@ -0,0 +1,306 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+)
+
+func TestFetcher(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		fmt.Fprint(w, "# TYPE a gauge\na{\"my.label\"=\"x\"} 1\n# TYPE b gauge\nb 2\n")
+	}))
+	defer server.Close()
+
+	fetch := func(f *Fetcher) (int, error) {
+		mfChan := make(chan *dto.MetricFamily, 10)
+		err := f.Fetch(context.Background(), server.URL, mfChan)
+		n := 0
+		for range mfChan {
+			n++
+		}
+		return n, err
+	}
+
+	f := &Fetcher{
+		Header:         http.Header{"X-Custom": {"1", "2"}},
+		BearerToken:    "secret",
+		EscapingScheme: "underscores",
+		Formats:        []Format{FormatOpenMetrics1, FormatText0},
+		UserAgent:      "prom2json/test",
+	}
+	if n, err := fetch(f); err != nil || n != 2 {
+		t.Fatalf("expected 2 metric families and no error, got %d and %v", n, err)
+	}
+	for name, expected := range map[string]string{
+		"Accept":        "application/openmetrics-text;version=1.0.0;escaping=underscores;q=0.6,text/plain;version=0.0.4;escaping=underscores;q=0.5",
+		"Authorization": "Bearer secret",
+		"User-Agent":    "prom2json/test",
+	} {
+		if got := header.Get(name); got != expected {
+			t.Errorf("expected %s header %q, got %q", name, expected, got)
+		}
+	}
+	if got := header.Values("X-Custom"); len(got) != 2 {
+		t.Errorf("expected two X-Custom headers, got %q", got)
+	}
+
+	f = &Fetcher{Username: "user", Password: "pass", Header: http.Header{"Accept": {"text/plain"}}}
+	if _, err := fetch(f); err != nil {
+		t.Fatal(err)
+	}
+	if user, pass, ok := (&http.Request{Header: header}).BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("unexpected basic auth %q:%q", user, pass)
+	}
+	if got := header.Get("Accept"); got != "text/plain" {
+		t.Errorf("expected overridden Accept header, got %q", got)
+	}
+
+	if _, err := fetch(&Fetcher{BodySizeLimit: 10}); !errors.Is(err, ErrBodySizeLimit) {
+		t.Errorf("expected body size limit error, got %v", err)
+	}
+	if _, err := fetch(&Fetcher{BodySizeLimit: 1000}); err != nil {
+		t.Errorf("unexpected error within body size limit: %v", err)
+	}
+	if _, err := fetch(&Fetcher{ValidationScheme: model.LegacyValidation}); err == nil {
+		t.Error("expected error for legacy validation of UTF-8 label name")
+	}
+
+	for name, f := range map[string]*Fetcher{
+		"unknown format":   {Formats: []Format{"JSON"}},
+		"duplicate format": {Formats: []Format{FormatText0, FormatText0}},
+		"conflicting auth": {Username: "user", BearerToken: "secret"},
+	} {
+		if _, err := fetch(f); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
+
+func TestFetcherMethodAndBody(t *testing.T) {
+	var method, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, contentType, body = r.Method, r.Header.Get("Content-Type"), string(b)
+		fmt.Fprint(w, "# TYPE a gauge\na 1\n")
+	}))
+	defer server.Close()
+
+	if _, err := fetchAll(&Fetcher{Method: http.MethodPost, Body: []byte("a=b")}, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || contentType != "application/x-www-form-urlencoded" || body != "a=b" {
+		t.Errorf("unexpected request: %s with Content-Type %q and body %q", method, contentType, body)
+	}
+	f := &Fetcher{Body: []byte("{}"), Header: http.Header{"Content-Type": {"application/json"}}}
+	if _, err := fetchAll(f, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || contentType != "application/json" || body != "{}" {
+		t.Errorf("unexpected request: %s with Content-Type %q and body %q", method, contentType, body)
+	}
+	if _, err := fetchAll(&Fetcher{Method: http.MethodPut, Body: []byte{}}, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || contentType != "application/x-www-form-urlencoded" || body != "" {
+		t.Errorf("unexpected request: %s with Content-Type %q and body %q", method, contentType, body)
+	}
+	if _, err := fetchAll(&Fetcher{}, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodGet || contentType != "" || body != "" {
+		t.Errorf("unexpected request: %s with Content-Type %q and body %q without body", method, contentType, body)
+	}
+}
+
+func TestFetcherHostHeader(t *testing.T) {
+	var host string
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, header = r.Host, r.Header
+		fmt.Fprint(w, "# TYPE a gauge\na 1\n")
+	}))
+	defer server.Close()
+
+	if _, err := fetchAll(&Fetcher{}, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.TrimPrefix(server.URL, "http://"); host != expected {
+		t.Errorf("expected host %q, got %q", expected, host)
+	}
+	f := &Fetcher{Header: http.Header{"Host": {"metrics.example.org"}, "X-Scope-Orgid": {"tenant1"}}}
+	if _, err := fetchAll(f, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if host != "metrics.example.org" || header.Get("X-Scope-OrgID") != "tenant1" {
+		t.Errorf("unexpected host %q and X-Scope-OrgID %q", host, header.Get("X-Scope-OrgID"))
+	}
+
+	// The same applies to a Unix domain socket, with localhost as the
+	// default host.
+	dir, err := os.MkdirTemp("", "prom2json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "metrics.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unixServer := httptest.NewUnstartedServer(server.Config.Handler)
+	unixServer.Listener = l
+	unixServer.Start()
+	defer unixServer.Close()
+	for expected, f := range map[string]*Fetcher{
+		"localhost":           {},
+		"metrics.example.org": {Header: http.Header{"Host": {"metrics.example.org"}}},
+	} {
+		if _, err := fetchAll(f, "unix://"+socket); err != nil {
+			t.Fatal(err)
+		}
+		if host != expected {
+			t.Errorf("expected host %q via Unix domain socket, got %q", expected, host)
+		}
+	}
+}
+
+// fetchAll fetches url with f and returns the names of the fetched metric
+// families.
+func fetchAll(f *Fetcher, url string) ([]string, error) {
+	mfChan := make(chan *dto.MetricFamily, 10)
+	err := f.Fetch(context.Background(), url, mfChan)
+	var names []string
+	for mf := range mfChan {
+		names = append(names, mf.GetName())
+	}
+	return names, err
+}
+
+func TestFetchUnixSocket(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, so t.TempDir() might
+	// be too long.
+	dir, err := os.MkdirTemp("", "prom2json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "metrics.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# TYPE path gauge\npath{path=%q,host=%q} 1\n", r.URL.Path, r.Host)
+	}))
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	// A stand-in proxy that must not be used.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request via proxy: %s", r.URL)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	client, err := config.NewClientFromConfig(
+		config.HTTPClientConfig{ProxyConfig: config.ProxyConfig{ProxyURL: config.URL{URL: proxyURL}, NoProxy: "localhost"}},
+		"test", config.WithDialContextFunc(DialContext),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, f := range map[string]*Fetcher{
+		"default transport": {},
+		"custom client":     {Client: client},
+	} {
+		for target, path := range map[string]string{
+			"unix://" + socket + ":/custom/path": "/custom/path",
+			"unix://" + socket:                   "/metrics",
+		} {
+			mfChan := make(chan *dto.MetricFamily, 10)
+			if err := f.Fetch(context.Background(), target, mfChan); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			mf := <-mfChan
+			if mf == nil {
+				t.Fatalf("%s: no metric family for %s", name, target)
+			}
+			labels := makeLabels(mf.Metric[0])
+			if labels["path"] != path || labels["host"] != "localhost" {
+				t.Errorf("%s: expected path %q and host localhost, got %v", name, path, labels)
+			}
+		}
+	}
+
+	if _, err := fetchAll(&Fetcher{}, "unix://"+filepath.Join(dir, "missing.sock")); err == nil {
+		t.Error("expected error for missing socket")
+	}
+}
+
+func TestFetchViaProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# TYPE direct gauge\ndirect 1\n")
+	}))
+	defer target.Close()
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A stand-in for a forward proxy, which answers itself.
+		proxied = append(proxied, r.URL.String())
+		fmt.Fprint(w, "# TYPE proxied gauge\nproxied 1\n")
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	client, err := config.NewClientFromConfig(
+		config.HTTPClientConfig{ProxyConfig: config.ProxyConfig{ProxyURL: config.URL{URL: proxyURL}, NoProxy: "127.0.0.1"}},
+		"test", config.WithDialContextFunc(DialContext),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fetcher{Client: client}
+
+	names, err := fetchAll(f, "http://target.example/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "proxied" || len(proxied) != 1 || proxied[0] != "http://target.example/metrics" {
+		t.Errorf("expected request via proxy, got %v and proxied requests %v", names, proxied)
+	}
+	names, err = fetchAll(f, target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "direct" || len(proxied) != 1 {
+		t.Errorf("expected direct request for no_proxy host, got %v and proxied requests %v", names, proxied)
+	}
+}
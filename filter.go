@@ -0,0 +1,134 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"fmt"
+	"regexp"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// Filter selects metric families by name and series by PromQL-style series
+// selectors. The zero value selects everything.
+type Filter struct {
+	// If not nil, Include has to match the name of a metric family for it
+	// to be selected.
+	Include *regexp.Regexp
+	// If not nil, Exclude must not match the name of a metric family for it
+	// to be selected.
+	Exclude *regexp.Regexp
+	// If not empty, a series is only selected if it matches all the
+	// matchers of at least one of the MatcherSets. The metric name a
+	// matcher for "__name__" is applied to is the name of the metric
+	// family, e.g. the name without the "_bucket", "_sum", or "_count"
+	// suffix for a histogram. The "quantile" label of a summary and the
+	// "le" label of a histogram cannot be matched.
+	MatcherSets [][]*labels.Matcher
+}
+
+// NewFilter creates a Filter from PromQL series selectors (like
+// `http_requests_total{code=~"5.."}`) and from regular expressions to include
+// and exclude metric families by name. Empty regular expressions are ignored.
+// Like in PromQL, the regular expressions are fully anchored.
+func NewFilter(selectors []string, include, exclude string) (*Filter, error) {
+	f := &Filter{}
+	p := parser.NewParser(parser.Options{})
+	for _, s := range selectors {
+		matchers, err := p.ParseMetricSelector(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid series selector %q: %w", s, err)
+		}
+		f.MatcherSets = append(f.MatcherSets, matchers)
+	}
+	var err error
+	if include != "" {
+		if f.Include, err = regexp.Compile("^(?:" + include + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid include regexp: %w", err)
+		}
+	}
+	if exclude != "" {
+		if f.Exclude, err = regexp.Compile("^(?:" + exclude + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid exclude regexp: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// Apply returns a MetricFamily with only the selected series of the provided
+// MetricFamily, or nil if no series is selected. The returned MetricFamily is
+// mf itself if all its series are selected.
+func (f *Filter) Apply(mf *dto.MetricFamily) *dto.MetricFamily {
+	name := mf.GetName()
+	if f.Include != nil && !f.Include.MatchString(name) {
+		return nil
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(name) {
+		return nil
+	}
+	if len(f.MatcherSets) == 0 {
+		return mf
+	}
+	var selected []*dto.Metric
+	for _, m := range mf.Metric {
+		if f.matches(name, m) {
+			selected = append(selected, m)
+		}
+	}
+	switch len(selected) {
+	case 0:
+		return nil
+	case len(mf.Metric):
+		return mf
+	}
+	return &dto.MetricFamily{
+		Name:   mf.Name,
+		Help:   mf.Help,
+		Type:   mf.Type,
+		Unit:   mf.Unit,
+		Metric: selected,
+	}
+}
+
+func (f *Filter) matches(name string, m *dto.Metric) bool {
+	lset := makeLabels(m)
+	lset[model.MetricNameLabel] = name
+	for _, matchers := range f.MatcherSets {
+		matchesAll := true
+		for _, matcher := range matchers {
+			if !matcher.Matches(lset[matcher.Name]) {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterMetricFamilies applies the Filter to each MetricFamily received from
+// in and sends the result (if any) to out. It closes out once in is closed.
+func FilterMetricFamilies(f *Filter, in <-chan *dto.MetricFamily, out chan<- *dto.MetricFamily) {
+	defer close(out)
+	for mf := range in {
+		if mf = f.Apply(mf); mf != nil {
+			out <- mf
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestFilter(t *testing.T) {
+	families := []*dto.MetricFamily{
+		{
+			Name: strPtr("http_requests_total"),
+			Type: metricTypePtr(dto.MetricType_COUNTER),
+			Metric: []*dto.Metric{
+				{Label: []*dto.LabelPair{createLabelPair("code", "200")}, Counter: &dto.Counter{Value: floatPtr(1)}},
+				{Label: []*dto.LabelPair{createLabelPair("code", "500")}, Counter: &dto.Counter{Value: floatPtr(2)}},
+				{Label: []*dto.LabelPair{createLabelPair("code", "503")}, Counter: &dto.Counter{Value: floatPtr(3)}},
+			},
+		},
+		{
+			Name: strPtr("go_goroutines"),
+			Type: metricTypePtr(dto.MetricType_GAUGE),
+			Metric: []*dto.Metric{
+				{Gauge: &dto.Gauge{Value: floatPtr(4)}},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name             string
+		selectors        []string
+		include, exclude string
+		expected         map[string]int // Number of series per selected family.
+	}{
+		{
+			name:     "no filter",
+			expected: map[string]int{"http_requests_total": 3, "go_goroutines": 1},
+		},
+		{
+			name:      "selector with regexp",
+			selectors: []string{`http_requests_total{code=~"5.."}`},
+			expected:  map[string]int{"http_requests_total": 2},
+		},
+		{
+			name:      "several selectors",
+			selectors: []string{`{code="200"}`, `go_goroutines`},
+			expected:  map[string]int{"http_requests_total": 1, "go_goroutines": 1},
+		},
+		{
+			name:      "selector matching missing label",
+			selectors: []string{`{__name__=~".+", code=""}`},
+			expected:  map[string]int{"go_goroutines": 1},
+		},
+		{
+			name:     "include is anchored",
+			include:  "go_.*|http",
+			expected: map[string]int{"go_goroutines": 1},
+		},
+		{
+			name:      "exclude and selector",
+			selectors: []string{`{code!="500"}`},
+			exclude:   "go_.*",
+			expected:  map[string]int{"http_requests_total": 2},
+		},
+	} {
+		f, err := NewFilter(tc.selectors, tc.include, tc.exclude)
+		if err != nil {
+			t.Fatalf("test case %s: %v", tc.name, err)
+		}
+		in := make(chan *dto.MetricFamily, len(families))
+		out := make(chan *dto.MetricFamily, len(families))
+		for _, mf := range families {
+			in <- mf
+		}
+		close(in)
+		FilterMetricFamilies(f, in, out)
+		got := map[string]int{}
+		for mf := range out {
+			got[mf.GetName()] = len(mf.Metric)
+		}
+		if len(got) != len(tc.expected) {
+			t.Errorf("test case %s: expected %v, got %v", tc.name, tc.expected, got)
+			continue
+		}
+		for name, n := range tc.expected {
+			if got[name] != n {
+				t.Errorf("test case %s: expected %v, got %v", tc.name, tc.expected, got)
+				break
+			}
+		}
+	}
+	if len(families[0].Metric) != 3 {
+		t.Errorf("filtering modified the input")
+	}
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	for name, args := range map[string][3]string{
+		"invalid selector": {`a{`, "", ""},
+		"invalid include":  {"", "(", ""},
+		"invalid exclude":  {"", "", "["},
+	} {
+		var selectors []string
+		if args[0] != "" {
+			selectors = []string{args[0]}
+		}
+		if _, err := NewFilter(selectors, args[1], args[2]); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
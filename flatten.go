@@ -0,0 +1,97 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"maps"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// Sample is a single sample as it would appear in the Prometheus text format.
+type Sample struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	TimestampMs string            `json:"timestamp_ms,omitempty"`
+	Value       string            `json:"value"`
+}
+
+// Flatten expands the provided Family into Samples. A Summary is expanded into
+// one Sample per quantile (with a "quantile" label) and Samples for "_sum" and
+// "_count". A classic Histogram is expanded into one "_bucket" Sample per
+// bucket (with an "le" label) and Samples for "_sum" and "_count" (or
+// "_gsum" and "_gcount" for a gauge histogram). A native Histogram is only
+// expanded into the Samples for its sum and count, as its buckets have no
+// representation as Prometheus-style samples. Exemplars and created timestamps
+// are dropped.
+func Flatten(f *Family) []Sample {
+	var result []Sample
+	sample := func(suffix string, labels map[string]string, ts, value string) Sample {
+		return Sample{Name: f.Name + suffix, Labels: labels, TimestampMs: ts, Value: value}
+	}
+	sumSuffix, countSuffix := "_sum", "_count"
+	if f.Type == dto.MetricType_GAUGE_HISTOGRAM.String() {
+		sumSuffix, countSuffix = "_gsum", "_gcount"
+	}
+	for _, item := range f.Metrics {
+		switch m := item.(type) {
+		case Metric:
+			result = append(result, sample("", maps.Clone(nonNilMap(m.Labels)), m.TimestampMs, m.Value))
+		case Summary:
+			for _, q := range sortedKeys(m.Quantiles) {
+				result = append(result, sample("", withLabel(m.Labels, model.QuantileLabel, q), m.TimestampMs, m.Quantiles[q]))
+			}
+			result = append(result,
+				sample("_sum", maps.Clone(nonNilMap(m.Labels)), m.TimestampMs, m.Sum),
+				sample("_count", maps.Clone(nonNilMap(m.Labels)), m.TimestampMs, m.Count),
+			)
+		case Histogram:
+			if buckets, ok := m.Buckets.(map[string]string); ok {
+				for _, ub := range sortedKeys(buckets) {
+					result = append(result, sample("_bucket", withLabel(m.Labels, model.BucketLabel, ub), m.TimestampMs, buckets[ub]))
+				}
+			}
+			result = append(result,
+				sample(sumSuffix, maps.Clone(nonNilMap(m.Labels)), m.TimestampMs, m.Sum),
+				sample(countSuffix, maps.Clone(nonNilMap(m.Labels)), m.TimestampMs, m.Count),
+			)
+		}
+	}
+	return result
+}
+
+// withLabel returns a copy of labels with the provided label added.
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := maps.Clone(nonNilMap(labels))
+	result[name] = value
+	return result
+}
+
+// sortedKeys returns the keys of m, which have to be numbers, sorted
+// numerically.
+func sortedKeys(m map[string]string) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(result[i], 64)
+		b, _ := strconv.ParseFloat(result[j], 64)
+		return a < b
+	})
+	return result
+}
@@ -0,0 +1,140 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestFlatten(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		family   *Family
+		expected []Sample
+	}{
+		{
+			name: "counter",
+			family: &Family{
+				Name: "a_total",
+				Type: "COUNTER",
+				Metrics: []any{
+					Metric{Labels: map[string]string{"x": "1"}, TimestampMs: "123", Value: "1"},
+					Metric{Value: "2"},
+				},
+			},
+			expected: []Sample{
+				{Name: "a_total", Labels: map[string]string{"x": "1"}, TimestampMs: "123", Value: "1"},
+				{Name: "a_total", Labels: map[string]string{}, Value: "2"},
+			},
+		},
+		{
+			name: "summary",
+			family: &Family{
+				Name: "b",
+				Type: "SUMMARY",
+				Metrics: []any{
+					Summary{
+						Labels:    map[string]string{"x": "1"},
+						Quantiles: map[string]string{"0.99": "3", "0.5": "1", "0.9": "2"},
+						Count:     "10",
+						Sum:       "15",
+					},
+				},
+			},
+			expected: []Sample{
+				{Name: "b", Labels: map[string]string{"x": "1", "quantile": "0.5"}, Value: "1"},
+				{Name: "b", Labels: map[string]string{"x": "1", "quantile": "0.9"}, Value: "2"},
+				{Name: "b", Labels: map[string]string{"x": "1", "quantile": "0.99"}, Value: "3"},
+				{Name: "b_sum", Labels: map[string]string{"x": "1"}, Value: "15"},
+				{Name: "b_count", Labels: map[string]string{"x": "1"}, Value: "10"},
+			},
+		},
+		{
+			name: "histograms",
+			family: &Family{
+				Name: "c",
+				Type: "HISTOGRAM",
+				Metrics: []any{
+					Histogram{
+						Buckets: map[string]string{"+Inf": "5", "1e+06": "4", "250000": "3"},
+						Count:   "5",
+						Sum:     "2",
+					},
+					Histogram{
+						Buckets: [][]any{{uint64(0), "1", "2", "3"}},
+						Count:   "3",
+						Sum:     "4.5",
+					},
+				},
+			},
+			expected: []Sample{
+				{Name: "c_bucket", Labels: map[string]string{"le": "250000"}, Value: "3"},
+				{Name: "c_bucket", Labels: map[string]string{"le": "1e+06"}, Value: "4"},
+				{Name: "c_bucket", Labels: map[string]string{"le": "+Inf"}, Value: "5"},
+				{Name: "c_sum", Labels: map[string]string{}, Value: "2"},
+				{Name: "c_count", Labels: map[string]string{}, Value: "5"},
+				{Name: "c_sum", Labels: map[string]string{}, Value: "4.5"},
+				{Name: "c_count", Labels: map[string]string{}, Value: "3"},
+			},
+		},
+		{
+			name: "gauge histogram",
+			family: &Family{
+				Name:    "d",
+				Type:    "GAUGE_HISTOGRAM",
+				Metrics: []any{Histogram{Buckets: map[string]string{"+Inf": "1"}, Count: "1", Sum: "1"}},
+			},
+			expected: []Sample{
+				{Name: "d_bucket", Labels: map[string]string{"le": "+Inf"}, Value: "1"},
+				{Name: "d_gsum", Labels: map[string]string{}, Value: "1"},
+				{Name: "d_gcount", Labels: map[string]string{}, Value: "1"},
+			},
+		},
+	} {
+		output := Flatten(tc.family)
+		if !reflect.DeepEqual(tc.expected, output) {
+			t.Errorf("test case %s: flattening failed:\nexpected:\n%s\n\nactual:\n%s",
+				tc.name, spew.Sdump(tc.expected), spew.Sdump(output))
+		}
+	}
+}
+
+func TestFlattenOpenMetricsGaugeHistogram(t *testing.T) {
+	in := `# TYPE queue_size gaugehistogram
+queue_size_bucket{le="1"} 2
+queue_size_bucket{le="+Inf"} 6
+queue_size_gcount 6
+queue_size_gsum 23.5
+# EOF
+`
+	mfChan := make(chan *dto.MetricFamily, 10)
+	if err := ParseReader(strings.NewReader(in), mfChan); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{
+		{Name: "queue_size_bucket", Labels: map[string]string{"le": "1"}, Value: "2"},
+		{Name: "queue_size_bucket", Labels: map[string]string{"le": "+Inf"}, Value: "6"},
+		{Name: "queue_size_gsum", Labels: map[string]string{}, Value: "23.5"},
+		{Name: "queue_size_gcount", Labels: map[string]string{}, Value: "6"},
+	}
+	output := Flatten(NewFamily(<-mfChan))
+	if !reflect.DeepEqual(expected, output) {
+		t.Errorf("flattening failed:\nexpected:\n%s\n\nactual:\n%s", spew.Sdump(expected), spew.Sdump(output))
+	}
+}
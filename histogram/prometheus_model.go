@@ -14,6 +14,8 @@ package histogram
 
 import (
 	"fmt"
+	"math"
+	"slices"
 
 	dto "github.com/prometheus/client_model/go"
 	model "github.com/prometheus/prometheus/model/histogram"
@@ -25,6 +27,14 @@ type APIBucket[BC model.BucketCount] struct {
 	Count        BC
 }
 
+// Contains returns whether v lies within the boundaries of the bucket.
+func (b APIBucket[BC]) Contains(v float64) bool {
+	lowerInclusive := b.Boundaries == 1 || b.Boundaries == 3
+	upperInclusive := b.Boundaries == 0 || b.Boundaries == 3
+	return (v > b.Lower || lowerInclusive && v == b.Lower) &&
+		(v < b.Upper || upperInclusive && v == b.Upper)
+}
+
 func NewModelHistogram(ch *dto.Histogram) (*model.Histogram, *model.FloatHistogram) {
 	if ch.GetSampleCountFloat() > 0 || ch.GetZeroCountFloat() > 0 {
 		// It is a float histogram.
@@ -151,3 +161,214 @@ func makeBucket[BC model.BucketCount](bucket model.Bucket[BC]) APIBucket[BC] {
 		Count:      bucket.Count,
 	}
 }
+
+// NewDTOHistogram is the inverse of NewModelHistogram. Exactly one of h and fh
+// must be non-nil.
+func NewDTOHistogram(h *model.Histogram, fh *model.FloatHistogram) *dto.Histogram {
+	var ch *dto.Histogram
+	if h == nil {
+		ch = &dto.Histogram{
+			SampleCountFloat: &fh.Count,
+			SampleSum:        &fh.Sum,
+			ZeroThreshold:    &fh.ZeroThreshold,
+			ZeroCountFloat:   &fh.ZeroCount,
+			Schema:           &fh.Schema,
+			PositiveSpan:     makeDTOSpans(fh.PositiveSpans),
+			PositiveCount:    fh.PositiveBuckets,
+			NegativeSpan:     makeDTOSpans(fh.NegativeSpans),
+			NegativeCount:    fh.NegativeBuckets,
+		}
+	} else {
+		ch = &dto.Histogram{
+			SampleCount:   &h.Count,
+			SampleSum:     &h.Sum,
+			ZeroThreshold: &h.ZeroThreshold,
+			ZeroCount:     &h.ZeroCount,
+			Schema:        &h.Schema,
+			PositiveSpan:  makeDTOSpans(h.PositiveSpans),
+			PositiveDelta: h.PositiveBuckets,
+			NegativeSpan:  makeDTOSpans(h.NegativeSpans),
+			NegativeDelta: h.NegativeBuckets,
+		}
+	}
+	if len(ch.PositiveSpan)+len(ch.NegativeSpan) == 0 {
+		// A native histogram is marked by at least one span, so add an
+		// empty one, like the client libraries do for a native
+		// histogram without any populated buckets.
+		offset, length := int32(0), uint32(0)
+		ch.PositiveSpan = []*dto.BucketSpan{{Offset: &offset, Length: &length}}
+	}
+	return ch
+}
+
+func makeDTOSpans(spans []model.Span) []*dto.BucketSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+	ret := make([]*dto.BucketSpan, len(spans))
+	for i, span := range spans {
+		ret[i] = &dto.BucketSpan{Offset: &span.Offset, Length: &span.Length}
+	}
+	return ret
+}
+
+// FromAPIBuckets is the inverse of GetAPIBuckets. It infers the schema, the
+// zero bucket, and the spans from the bucket boundaries. Count and Sum of the
+// returned Histogram are left for the caller to set. Note that the zero
+// threshold cannot be restored if the zero bucket is empty.
+func FromAPIBuckets(buckets []APIBucket[uint64]) (*model.Histogram, error) {
+	l, err := newBucketLayout(buckets)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Histogram{
+		Schema:          l.schema,
+		ZeroThreshold:   l.zeroThreshold,
+		ZeroCount:       l.zeroCount,
+		PositiveSpans:   l.positiveSpans,
+		PositiveBuckets: toDeltas(l.positiveCounts),
+		NegativeSpans:   l.negativeSpans,
+		NegativeBuckets: toDeltas(l.negativeCounts),
+	}, nil
+}
+
+// FromAPIFloatBuckets is the inverse of GetAPIFloatBuckets. See FromAPIBuckets
+// for details.
+func FromAPIFloatBuckets(buckets []APIBucket[float64]) (*model.FloatHistogram, error) {
+	l, err := newBucketLayout(buckets)
+	if err != nil {
+		return nil, err
+	}
+	return &model.FloatHistogram{
+		Schema:          l.schema,
+		ZeroThreshold:   l.zeroThreshold,
+		ZeroCount:       l.zeroCount,
+		PositiveSpans:   l.positiveSpans,
+		PositiveBuckets: l.positiveCounts,
+		NegativeSpans:   l.negativeSpans,
+		NegativeBuckets: l.negativeCounts,
+	}, nil
+}
+
+type bucketLayout[BC model.BucketCount] struct {
+	schema                         int32
+	zeroThreshold                  float64
+	zeroCount                      BC
+	positiveSpans, negativeSpans   []model.Span
+	positiveCounts, negativeCounts []BC
+}
+
+type indexedCount[BC model.BucketCount] struct {
+	index int32
+	count BC
+}
+
+func newBucketLayout[BC model.BucketCount](buckets []APIBucket[BC]) (*bucketLayout[BC], error) {
+	l := &bucketLayout[BC]{}
+	schemaKnown := false
+	var positive, negative []indexedCount[BC]
+	for _, b := range buckets {
+		if isZeroBucket(b) {
+			if b.Lower != -b.Upper {
+				return nil, fmt.Errorf("bucket [%v,%v] spans zero but is not a zero bucket", b.Lower, b.Upper)
+			}
+			l.zeroThreshold = b.Upper
+			l.zeroCount = b.Count
+			continue
+		}
+		// The smaller absolute value of the two boundaries is always finite.
+		lower, upper := b.Lower, b.Upper
+		if upper <= 0 {
+			lower, upper = -upper, -lower
+		}
+		if lower <= 0 {
+			return nil, fmt.Errorf("bucket [%v,%v] has no exponential boundaries", b.Lower, b.Upper)
+		}
+		if !math.IsInf(upper, 0) && upper != math.MaxFloat64 {
+			schema := -int32(math.Round(math.Log2(math.Log2(upper / lower))))
+			if !schemaKnown {
+				l.schema, schemaKnown = schema, true
+			} else if schema != l.schema {
+				return nil, fmt.Errorf("bucket [%v,%v] does not match schema %d", b.Lower, b.Upper, l.schema)
+			}
+		}
+		// The index is calculated from lower below, which requires a
+		// known schema. Hence, collect the boundaries for now.
+		if b.Upper > 0 {
+			positive = append(positive, indexedCount[BC]{count: b.Count})
+		} else {
+			negative = append(negative, indexedCount[BC]{count: b.Count})
+		}
+	}
+	// Second pass to calculate indices now that the schema is known.
+	var pi, ni int
+	for _, b := range buckets {
+		switch {
+		case isZeroBucket(b):
+			continue
+		case b.Upper > 0:
+			positive[pi].index = bucketIndex(b.Lower, l.schema)
+			pi++
+		default:
+			negative[ni].index = bucketIndex(-b.Upper, l.schema)
+			ni++
+		}
+	}
+	// Negative buckets are ordered from the lowest to the highest
+	// boundary, i.e. by descending index.
+	slices.Reverse(negative)
+	var err error
+	if l.positiveSpans, l.positiveCounts, err = makeSpans(positive); err != nil {
+		return nil, err
+	}
+	if l.negativeSpans, l.negativeCounts, err = makeSpans(negative); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// isZeroBucket returns whether b spans zero, i.e. whether it is the zero
+// bucket. With a zero threshold of 0, the zero bucket is [-0,0].
+func isZeroBucket[BC model.BucketCount](b APIBucket[BC]) bool {
+	return b.Lower < 0 && b.Upper > 0 || b.Lower == 0 && b.Upper == 0
+}
+
+// bucketIndex returns the index of the bucket with the provided lower
+// boundary (by absolute value) in the provided schema.
+func bucketIndex(lower float64, schema int32) int32 {
+	return int32(math.Round(math.Ldexp(math.Log2(lower), int(schema)))) + 1
+}
+
+func makeSpans[BC model.BucketCount](buckets []indexedCount[BC]) ([]model.Span, []BC, error) {
+	if len(buckets) == 0 {
+		return nil, nil, nil
+	}
+	spans := []model.Span{{Offset: buckets[0].index, Length: 1}}
+	counts := []BC{buckets[0].count}
+	for i := 1; i < len(buckets); i++ {
+		gap := buckets[i].index - buckets[i-1].index - 1
+		if gap < 0 {
+			return nil, nil, fmt.Errorf("buckets with index %d are not in order or duplicated", buckets[i].index)
+		}
+		if gap == 0 {
+			spans[len(spans)-1].Length++
+		} else {
+			spans = append(spans, model.Span{Offset: gap, Length: 1})
+		}
+		counts = append(counts, buckets[i].count)
+	}
+	return spans, counts, nil
+}
+
+func toDeltas(counts []uint64) []int64 {
+	if len(counts) == 0 {
+		return nil
+	}
+	deltas := make([]int64, len(counts))
+	var prev int64
+	for i, c := range counts {
+		deltas[i] = int64(c) - prev
+		prev = int64(c)
+	}
+	return deltas
+}
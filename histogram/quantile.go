@@ -0,0 +1,172 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package histogram
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
+
+	model "github.com/prometheus/prometheus/model/histogram"
+)
+
+// Bucket is a bucket of a classic histogram.
+type Bucket struct {
+	UpperBound      float64
+	CumulativeCount float64
+}
+
+// BucketQuantile estimates the q-quantile of the observations in a classic
+// histogram from its buckets, which need not be sorted. It works like the
+// PromQL function histogram_quantile does for classic histograms: The result
+// is interpolated linearly within the bucket the quantile falls into, with a
+// lower bound of 0 for the lowest bucket if its upper bound is positive. If the
+// quantile falls into the +Inf bucket, the upper bound of the second highest
+// bucket is returned.
+//
+// NaN is returned if q is NaN, if there are fewer than two buckets, if the
+// highest bucket is not the +Inf bucket, or if there are no observations. -Inf
+// is returned if q < 0, +Inf if q > 1.
+func BucketQuantile(q float64, buckets []Bucket) float64 {
+	switch {
+	case math.IsNaN(q):
+		return math.NaN()
+	case q < 0:
+		return math.Inf(-1)
+	case q > 1:
+		return math.Inf(+1)
+	}
+	buckets = slices.Clone(buckets)
+	slices.SortFunc(buckets, func(a, b Bucket) int {
+		return cmp.Compare(a.UpperBound, b.UpperBound)
+	})
+	if len(buckets) < 2 || !math.IsInf(buckets[len(buckets)-1].UpperBound, +1) {
+		return math.NaN()
+	}
+	// Counts of a scraped histogram are monotonic, but they may not be
+	// after calculating a rate or similar.
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i].CumulativeCount < buckets[i-1].CumulativeCount {
+			buckets[i].CumulativeCount = buckets[i-1].CumulativeCount
+		}
+	}
+	observations := buckets[len(buckets)-1].CumulativeCount
+	if observations == 0 {
+		return math.NaN()
+	}
+	rank := q * observations
+	b := sort.Search(len(buckets)-1, func(i int) bool { return buckets[i].CumulativeCount >= rank })
+	switch {
+	case b == len(buckets)-1:
+		return buckets[len(buckets)-2].UpperBound
+	case b == 0 && buckets[0].UpperBound <= 0:
+		return buckets[0].UpperBound
+	}
+	var (
+		bucketStart float64
+		bucketEnd   = buckets[b].UpperBound
+		count       = buckets[b].CumulativeCount
+	)
+	if b > 0 {
+		bucketStart = buckets[b-1].UpperBound
+		count -= buckets[b-1].CumulativeCount
+		rank -= buckets[b-1].CumulativeCount
+	}
+	return bucketStart + (bucketEnd-bucketStart)*(rank/count)
+}
+
+// Quantile estimates the q-quantile of the observations in a native histogram
+// with exponential buckets. It works like the PromQL function
+// histogram_quantile does for native histograms: The result is interpolated
+// exponentially within the bucket the quantile falls into, i.e. as if the
+// observations in the bucket uniformly populated the buckets of a histogram
+// with a higher resolution, but linearly within the zero bucket. The zero
+// bucket is considered to start (or end) at 0 if there are only positive (or
+// negative) buckets.
+//
+// NaN is returned if q is NaN or if there are no observations, and also if the
+// quantile falls above all buckets, which happens if NaN was observed. -Inf is
+// returned if q < 0, +Inf if q > 1.
+func Quantile(q float64, h *model.FloatHistogram) float64 {
+	if q < 0 {
+		return math.Inf(-1)
+	}
+	if q > 1 {
+		return math.Inf(+1)
+	}
+	if h.Count == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	var (
+		bucket model.Bucket[float64]
+		count  float64
+		it     model.BucketIterator[float64]
+		rank   float64
+	)
+	// Iterate from the closer end, unless NaN has been observed, which
+	// adds to the count, but not to any bucket.
+	forward := math.IsNaN(h.Sum) || q < 0.5
+	if forward {
+		it = h.AllBucketIterator()
+		rank = q * h.Count
+	} else {
+		it = h.AllReverseBucketIterator()
+		rank = (1 - q) * h.Count
+	}
+	for it.Next() {
+		bucket = it.At()
+		if bucket.Count == 0 {
+			continue
+		}
+		count += bucket.Count
+		if count >= rank {
+			break
+		}
+	}
+	if bucket.Lower < 0 && bucket.Upper > 0 {
+		switch {
+		case len(h.NegativeBuckets) == 0 && len(h.PositiveBuckets) > 0:
+			bucket.Lower = 0
+		case len(h.PositiveBuckets) == 0 && len(h.NegativeBuckets) > 0:
+			bucket.Upper = 0
+		}
+	}
+	// Guard against numerical inaccuracies.
+	count = min(count, h.Count)
+	if count < rank {
+		if math.IsNaN(h.Sum) {
+			return math.NaN()
+		}
+		return bucket.Upper
+	}
+	if forward {
+		rank -= count - bucket.Count
+	} else {
+		rank = count - rank
+	}
+	// The fraction of how far we are into the bucket.
+	fraction := rank / bucket.Count
+	if bucket.Lower <= 0 && bucket.Upper >= 0 {
+		return bucket.Lower + (bucket.Upper-bucket.Lower)*fraction
+	}
+	// On a logarithmic scale, exponential buckets all have the same width,
+	// so interpolate linearly there.
+	logLower := math.Log2(math.Abs(bucket.Lower))
+	logUpper := math.Log2(math.Abs(bucket.Upper))
+	if bucket.Lower > 0 {
+		return math.Exp2(logLower + (logUpper-logLower)*fraction)
+	}
+	// A negative bucket, so mirror things.
+	return -math.Exp2(logUpper + (logLower-logUpper)*(1-fraction))
+}
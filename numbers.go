@@ -0,0 +1,273 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// NativeNumbers wraps a Family to marshal it to JSON with sample values,
+// counts, sums, quantile values, bucket counts and boundaries, and timestamps
+// encoded as JSON numbers rather than strings. The resulting JSON cannot be
+// decoded into a Family anymore.
+//
+// NaN and ±Inf cannot be represented as JSON numbers. They are encoded as the
+// JSON value in NonFinite instead, or as null if NonFinite is empty.
+type NativeNumbers struct {
+	Family    *Family
+	NonFinite json.RawMessage
+}
+
+// NativeSample wraps a Sample to marshal it to JSON with its value and
+// timestamp encoded as JSON numbers, just like NativeNumbers does for a
+// Family.
+type NativeSample struct {
+	Sample    Sample
+	NonFinite json.RawMessage
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s NativeSample) MarshalJSON() ([]byte, error) {
+	n := NativeNumbers{NonFinite: s.NonFinite}
+	return json.Marshal(struct {
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels,omitempty"`
+		TimestampMs any               `json:"timestamp_ms,omitempty"`
+		Value       any               `json:"value"`
+	}{
+		Name:        s.Sample.Name,
+		Labels:      s.Sample.Labels,
+		TimestampMs: n.optionalNumber(s.Sample.TimestampMs),
+		Value:       n.number(s.Sample.Value),
+	})
+}
+
+type nativeMetric struct {
+	Labels             map[string]string `json:"labels,omitempty"`
+	TimestampMs        any               `json:"timestamp_ms,omitempty"`
+	CreatedTimestampMs any               `json:"created_timestamp_ms,omitempty"`
+	Value              any               `json:"value"`
+	Exemplars          []nativeExemplar  `json:"exemplars,omitempty"`
+}
+
+type nativeSummary struct {
+	Labels             map[string]string `json:"labels,omitempty"`
+	TimestampMs        any               `json:"timestamp_ms,omitempty"`
+	CreatedTimestampMs any               `json:"created_timestamp_ms,omitempty"`
+	Quantiles          map[string]any    `json:"quantiles,omitempty"`
+	Count              any               `json:"count"`
+	Sum                any               `json:"sum"`
+}
+
+type nativeHistogram struct {
+	Labels             map[string]string `json:"labels,omitempty"`
+	TimestampMs        any               `json:"timestamp_ms,omitempty"`
+	CreatedTimestampMs any               `json:"created_timestamp_ms,omitempty"`
+	Buckets            any               `json:"buckets,omitempty"`
+	NativeBuckets      any               `json:"native_buckets,omitempty"`
+	Raw                *nativeRaw        `json:"raw,omitempty"`
+	Count              any               `json:"count"`
+	Sum                any               `json:"sum"`
+	Quantiles          map[string]any    `json:"quantiles,omitempty"`
+	Mean               any               `json:"mean,omitempty"`
+	Exemplars          []nativeExemplar  `json:"exemplars,omitempty"`
+	NativeExemplars    []nativeExemplar  `json:"native_exemplars,omitempty"`
+}
+
+type nativeRaw struct {
+	Schema         int32  `json:"schema"`
+	ZeroThreshold  any    `json:"zero_threshold"`
+	ZeroCount      any    `json:"zero_count"`
+	PositiveSpans  []Span `json:"positive_spans,omitempty"`
+	PositiveDeltas []any  `json:"positive_deltas,omitempty"`
+	PositiveCounts []any  `json:"positive_counts,omitempty"`
+	NegativeSpans  []Span `json:"negative_spans,omitempty"`
+	NegativeDeltas []any  `json:"negative_deltas,omitempty"`
+	NegativeCounts []any  `json:"negative_counts,omitempty"`
+}
+
+type nativeExemplar struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	TimestampMs any               `json:"timestamp_ms,omitempty"`
+	Value       any               `json:"value"`
+	Bucket      string            `json:"bucket,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NativeNumbers) MarshalJSON() ([]byte, error) {
+	f := *n.Family
+	f.Metrics = make([]any, len(n.Family.Metrics))
+	for i, m := range n.Family.Metrics {
+		f.Metrics[i] = n.convert(m)
+	}
+	return json.Marshal(f)
+}
+
+func (n NativeNumbers) convert(m any) any {
+	switch m := m.(type) {
+	case Metric:
+		return nativeMetric{
+			Labels:             m.Labels,
+			TimestampMs:        n.optionalNumber(m.TimestampMs),
+			CreatedTimestampMs: n.optionalNumber(m.CreatedTimestampMs),
+			Value:              n.number(m.Value),
+			Exemplars:          n.exemplars(m.Exemplars),
+		}
+	case Summary:
+		return nativeSummary{
+			Labels:             m.Labels,
+			TimestampMs:        n.optionalNumber(m.TimestampMs),
+			CreatedTimestampMs: n.optionalNumber(m.CreatedTimestampMs),
+			Quantiles:          n.quantiles(m.Quantiles),
+			Count:              n.number(m.Count),
+			Sum:                n.number(m.Sum),
+		}
+	case Histogram:
+		return nativeHistogram{
+			Labels:             m.Labels,
+			TimestampMs:        n.optionalNumber(m.TimestampMs),
+			CreatedTimestampMs: n.optionalNumber(m.CreatedTimestampMs),
+			Buckets:            n.buckets(m.Buckets),
+			NativeBuckets:      n.buckets(m.NativeBuckets),
+			Raw:                n.raw(m.Raw),
+			Count:              n.number(m.Count),
+			Sum:                n.number(m.Sum),
+			Quantiles:          n.quantiles(m.Quantiles),
+			Mean:               n.optionalNumber(m.Mean),
+			Exemplars:          n.exemplars(m.Exemplars),
+			NativeExemplars:    n.exemplars(m.NativeExemplars),
+		}
+	default:
+		return m
+	}
+}
+
+func (n NativeNumbers) quantiles(quantiles map[string]string) map[string]any {
+	if quantiles == nil {
+		return nil
+	}
+	result := make(map[string]any, len(quantiles))
+	for q, v := range quantiles {
+		result[q] = n.number(v)
+	}
+	return result
+}
+
+func (n NativeNumbers) buckets(buckets any) any {
+	switch buckets := buckets.(type) {
+	case map[string]string:
+		result := make(map[string]any, len(buckets))
+		for ub, c := range buckets {
+			result[ub] = n.number(c)
+		}
+		return result
+	case [][]any:
+		result := make([][]any, len(buckets))
+		for i, b := range buckets {
+			result[i] = make([]any, len(b))
+			for j, v := range b {
+				if s, ok := v.(string); ok {
+					result[i][j] = n.number(s)
+				} else {
+					result[i][j] = v
+				}
+			}
+		}
+		return result
+	case []NativeBucket:
+		result := make([][]any, len(buckets))
+		for i, b := range buckets {
+			result[i] = []any{b.Boundaries, n.number(b.Lower), n.number(b.Upper), n.number(b.Count)}
+		}
+		return result
+	default:
+		return buckets
+	}
+}
+
+func (n NativeNumbers) raw(r *RawNativeHistogram) *nativeRaw {
+	if r == nil {
+		return nil
+	}
+	return &nativeRaw{
+		Schema:         r.Schema,
+		ZeroThreshold:  n.number(r.ZeroThreshold),
+		ZeroCount:      n.number(r.ZeroCount),
+		PositiveSpans:  r.PositiveSpans,
+		PositiveDeltas: n.numbers(r.PositiveDeltas),
+		PositiveCounts: n.numbers(r.PositiveCounts),
+		NegativeSpans:  r.NegativeSpans,
+		NegativeDeltas: n.numbers(r.NegativeDeltas),
+		NegativeCounts: n.numbers(r.NegativeCounts),
+	}
+}
+
+func (n NativeNumbers) numbers(numbers []string) []any {
+	if numbers == nil {
+		return nil
+	}
+	result := make([]any, len(numbers))
+	for i, s := range numbers {
+		result[i] = n.number(s)
+	}
+	return result
+}
+
+func (n NativeNumbers) exemplars(exemplars []Exemplar) []nativeExemplar {
+	if exemplars == nil {
+		return nil
+	}
+	result := make([]nativeExemplar, len(exemplars))
+	for i, e := range exemplars {
+		result[i] = nativeExemplar{
+			Labels:      e.Labels,
+			TimestampMs: n.optionalNumber(e.TimestampMs),
+			Value:       n.number(e.Value),
+			Bucket:      e.Bucket,
+		}
+	}
+	return result
+}
+
+// number converts s into a value that is marshaled as a JSON number. Integers
+// are kept as such to not lose precision. Strings that are not numbers at all
+// are returned unchanged.
+func (n NativeNumbers) number(s string) any {
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return u
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	switch {
+	case err != nil:
+		return s
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		if len(n.NonFinite) == 0 {
+			return nil
+		}
+		return n.NonFinite
+	default:
+		return f
+	}
+}
+
+// optionalNumber is like number, but returns nil for an empty string so that
+// the field is omitted.
+func (n NativeNumbers) optionalNumber(s string) any {
+	if s == "" {
+		return nil
+	}
+	return n.number(s)
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNativeNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		family    *Family
+		nonFinite json.RawMessage
+		expected  string
+	}{
+		{
+			name: "counter with exemplar",
+			family: &Family{
+				Name: "a",
+				Type: "COUNTER",
+				Metrics: []any{
+					Metric{
+						TimestampMs:        "1234",
+						CreatedTimestampMs: "1000",
+						Value:              "18446744073709551615",
+						Exemplars:          []Exemplar{{Value: "0.5", TimestampMs: "1200"}},
+					},
+					Metric{Value: "-1.5e-07"},
+				},
+			},
+			expected: `{"name":"a","help":"","type":"COUNTER","metrics":[` +
+				`{"timestamp_ms":1234,"created_timestamp_ms":1000,"value":18446744073709551615,"exemplars":[{"timestamp_ms":1200,"value":0.5}]},` +
+				`{"value":-1.5e-7}]}`,
+		},
+		{
+			name: "summary with default non-finite",
+			family: &Family{
+				Name: "b",
+				Type: "SUMMARY",
+				Metrics: []any{
+					Summary{Quantiles: map[string]string{"0.5": "NaN", "0.9": "2"}, Count: "3", Sum: "+Inf"},
+				},
+			},
+			expected: `{"name":"b","help":"","type":"SUMMARY","metrics":[{"quantiles":{"0.5":null,"0.9":2},"count":3,"sum":null}]}`,
+		},
+		{
+			name: "histograms with non-finite sentinel",
+			family: &Family{
+				Name: "c",
+				Type: "HISTOGRAM",
+				Metrics: []any{
+					Histogram{Buckets: map[string]string{"1": "1", "+Inf": "2"}, Count: "2", Sum: "NaN"},
+					Histogram{Buckets: [][]any{{uint64(0), "1", "2", "3"}}, Count: "3", Sum: "4.5"},
+					Histogram{Buckets: []NativeBucket{{Boundaries: 0, Lower: "1", Upper: "2", Count: "3.5"}}, Count: "3.5", Sum: "4.5"},
+				},
+			},
+			nonFinite: json.RawMessage(`"NaN"`),
+			expected: `{"name":"c","help":"","type":"HISTOGRAM","metrics":[` +
+				`{"buckets":{"+Inf":2,"1":1},"count":2,"sum":"NaN"},` +
+				`{"buckets":[[0,1,2,3]],"count":3,"sum":4.5},` +
+				`{"buckets":[[0,1,2,3.5]],"count":3.5,"sum":4.5}]}`,
+		},
+		{
+			name: "raw native histogram",
+			family: &Family{
+				Name: "d",
+				Type: "HISTOGRAM",
+				Metrics: []any{
+					Histogram{
+						Raw: &RawNativeHistogram{
+							Schema:         1,
+							ZeroThreshold:  "1e-128",
+							ZeroCount:      "1",
+							PositiveSpans:  []Span{{Offset: -1, Length: 2}},
+							PositiveDeltas: []string{"2", "-1"},
+							PositiveCounts: []string{"2", "1"},
+						},
+						Count: "4",
+						Sum:   "2.5",
+					},
+				},
+			},
+			expected: `{"name":"d","help":"","type":"HISTOGRAM","metrics":[` +
+				`{"raw":{"schema":1,"zero_threshold":1e-128,"zero_count":1,"positive_spans":[{"offset":-1,"length":2}],` +
+				`"positive_deltas":[2,-1],"positive_counts":[2,1]},"count":4,"sum":2.5}]}`,
+		},
+	} {
+		out, err := json.Marshal(NativeNumbers{Family: tc.family, NonFinite: tc.nonFinite})
+		if err != nil {
+			t.Errorf("test case %s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if string(out) != tc.expected {
+			t.Errorf("test case %s: unexpected JSON:\nexpected:\n%s\n\nactual:\n%s", tc.name, tc.expected, out)
+		}
+	}
+}
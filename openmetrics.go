@@ -0,0 +1,299 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/textparse"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const openMetricsType = "application/openmetrics-text"
+
+// isOpenMetrics detects the OpenMetrics text format by the mandatory "# EOF"
+// line at its end.
+func isOpenMetrics(in []byte) bool {
+	in = bytes.TrimRight(in, " \t\r\n")
+	if !bytes.HasSuffix(in, []byte("# EOF")) {
+		return false
+	}
+	in = in[:len(in)-len("# EOF")]
+	return len(in) == 0 || in[len(in)-1] == '\n'
+}
+
+// parseOpenMetrics parses the OpenMetrics text format and pushes the resulting
+// MetricFamilies to the sink. Unlike ParseReader, it does not close the
+// channel.
+//
+// Counters and info metrics are named after their samples, i.e. including the
+// "_total" or "_info" suffix, as the text format and the protobuf format would
+// name them. Info and stateset metrics become gauges. Created timestamps are
+// taken from the "_created" samples.
+func parseOpenMetrics(in []byte, s sink) error {
+	p := textparse.NewOpenMetricsParser(in, labels.NewSymbolTable(), textparse.WithOMParserSTSeriesSkipped())
+	var f *omFamily
+	// switchFamily sends the current family (if any) and starts a new one,
+	// unless name is the name of the current family.
+	switchFamily := func(name string) error {
+		if f != nil {
+			if f.name == name {
+				return nil
+			}
+			if len(f.mf.Metric) > 0 {
+				if err := s.send(f.mf); err != nil {
+					return err
+				}
+			}
+		}
+		f = newOMFamily(name)
+		return nil
+	}
+	for {
+		entry, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading OpenMetrics format failed: %w", err)
+		}
+		switch entry {
+		case textparse.EntryType:
+			name, t := p.Type()
+			if err := switchFamily(string(name)); err != nil {
+				return err
+			}
+			f.setType(t)
+		case textparse.EntryHelp:
+			name, help := p.Help()
+			if err := switchFamily(string(name)); err != nil {
+				return err
+			}
+			f.mf.Help = proto.String(string(help))
+		case textparse.EntryUnit:
+			name, unit := p.Unit()
+			if err := switchFamily(string(name)); err != nil {
+				return err
+			}
+			f.mf.Unit = proto.String(string(unit))
+		case textparse.EntrySeries:
+			var lset labels.Labels
+			p.Labels(&lset)
+			_, ts, v := p.Series()
+			name := lset.Get(model.MetricNameLabel)
+			suffix, ok := "", false
+			if f != nil {
+				suffix, ok = f.suffix(name)
+			}
+			if !ok {
+				// A sample without preceding metadata.
+				if err := switchFamily(name); err != nil {
+					return err
+				}
+			}
+			var e exemplar.Exemplar
+			var dtoE *dto.Exemplar
+			if p.Exemplar(&e) {
+				dtoE = makeDTOExemplarFromModel(e)
+			}
+			if err := f.add(name, suffix, lset, ts, v, p.StartTimestamp(), dtoE); err != nil {
+				return fmt.Errorf("reading OpenMetrics format failed: %w", err)
+			}
+		}
+	}
+	if f != nil && len(f.mf.Metric) > 0 {
+		return s.send(f.mf)
+	}
+	return nil
+}
+
+// omFamily assembles a MetricFamily from the samples of an OpenMetrics metric
+// family.
+type omFamily struct {
+	name    string
+	omType  model.MetricType
+	mf      *dto.MetricFamily
+	metrics map[string]*dto.Metric
+}
+
+func newOMFamily(name string) *omFamily {
+	return &omFamily{
+		name:    name,
+		omType:  model.MetricTypeUnknown,
+		mf:      &dto.MetricFamily{Name: proto.String(name), Type: dto.MetricType_UNTYPED.Enum()},
+		metrics: map[string]*dto.Metric{},
+	}
+}
+
+func (f *omFamily) setType(t model.MetricType) {
+	f.omType = t
+	switch t {
+	case model.MetricTypeCounter:
+		f.mf.Type = dto.MetricType_COUNTER.Enum()
+	case model.MetricTypeGauge, model.MetricTypeInfo, model.MetricTypeStateset:
+		f.mf.Type = dto.MetricType_GAUGE.Enum()
+	case model.MetricTypeHistogram:
+		f.mf.Type = dto.MetricType_HISTOGRAM.Enum()
+	case model.MetricTypeGaugeHistogram:
+		f.mf.Type = dto.MetricType_GAUGE_HISTOGRAM.Enum()
+	case model.MetricTypeSummary:
+		f.mf.Type = dto.MetricType_SUMMARY.Enum()
+	default:
+		f.mf.Type = dto.MetricType_UNTYPED.Enum()
+	}
+}
+
+// suffix returns the suffix of the provided sample name relative to the name
+// of the family, and whether the sample belongs to the family at all.
+func (f *omFamily) suffix(name string) (string, bool) {
+	suffix, ok := strings.CutPrefix(name, f.name)
+	if !ok {
+		return "", false
+	}
+	var allowed []string
+	switch f.omType {
+	case model.MetricTypeCounter:
+		allowed = []string{"", "_total"}
+	case model.MetricTypeInfo:
+		allowed = []string{"_info"}
+	case model.MetricTypeHistogram:
+		allowed = []string{"_bucket", "_count", "_sum"}
+	case model.MetricTypeGaugeHistogram:
+		allowed = []string{"_bucket", "_gcount", "_gsum"}
+	case model.MetricTypeSummary:
+		allowed = []string{"", "_count", "_sum"}
+	default:
+		allowed = []string{""}
+	}
+	for _, a := range allowed {
+		if suffix == a {
+			return suffix, true
+		}
+	}
+	return "", false
+}
+
+func (f *omFamily) add(
+	name, suffix string, lset labels.Labels, ts *int64, v float64, st int64, e *dto.Exemplar,
+) error {
+	b := labels.NewBuilder(lset).Del(model.MetricNameLabel)
+	switch {
+	case suffix == "_bucket":
+		b.Del(model.BucketLabel)
+	case f.omType == model.MetricTypeSummary && suffix == "":
+		b.Del(model.QuantileLabel)
+	}
+	metricLabels := b.Labels()
+	key := metricLabels.String()
+	m, ok := f.metrics[key]
+	if !ok {
+		m = &dto.Metric{}
+		metricLabels.Range(func(l labels.Label) {
+			m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(l.Name), Value: proto.String(l.Value)})
+		})
+		f.metrics[key] = m
+		f.mf.Metric = append(f.mf.Metric, m)
+	}
+	if ts != nil && m.TimestampMs == nil {
+		m.TimestampMs = proto.Int64(*ts)
+	}
+	var created *timestamppb.Timestamp
+	if st != 0 {
+		created = timestamppb.New(time.UnixMilli(st))
+	}
+
+	switch f.omType {
+	case model.MetricTypeCounter:
+		// Counters are named after their samples.
+		f.mf.Name = proto.String(name)
+		m.Counter = &dto.Counter{Value: proto.Float64(v), Exemplar: e, CreatedTimestamp: created}
+	case model.MetricTypeInfo:
+		f.mf.Name = proto.String(name)
+		m.Gauge = &dto.Gauge{Value: proto.Float64(v)}
+	case model.MetricTypeGauge, model.MetricTypeStateset:
+		m.Gauge = &dto.Gauge{Value: proto.Float64(v)}
+	case model.MetricTypeSummary:
+		if m.Summary == nil {
+			m.Summary = &dto.Summary{CreatedTimestamp: created}
+		}
+		switch suffix {
+		case "_count":
+			m.Summary.SampleCount = proto.Uint64(uint64(v))
+		case "_sum":
+			m.Summary.SampleSum = proto.Float64(v)
+		default:
+			q, err := strconv.ParseFloat(lset.Get(model.QuantileLabel), 64)
+			if err != nil {
+				return fmt.Errorf("invalid quantile label of %s: %w", lset, err)
+			}
+			m.Summary.Quantile = append(m.Summary.Quantile, &dto.Quantile{Quantile: proto.Float64(q), Value: proto.Float64(v)})
+		}
+	case model.MetricTypeHistogram, model.MetricTypeGaugeHistogram:
+		if m.Histogram == nil {
+			m.Histogram = &dto.Histogram{CreatedTimestamp: created}
+		}
+		switch suffix {
+		case "_count", "_gcount":
+			if isCount(v) {
+				m.Histogram.SampleCount = proto.Uint64(uint64(v))
+			} else {
+				m.Histogram.SampleCountFloat = proto.Float64(v)
+			}
+		case "_sum", "_gsum":
+			m.Histogram.SampleSum = proto.Float64(v)
+		default:
+			ub, err := strconv.ParseFloat(lset.Get(model.BucketLabel), 64)
+			if err != nil {
+				return fmt.Errorf("invalid le label of %s: %w", lset, err)
+			}
+			bucket := &dto.Bucket{UpperBound: proto.Float64(ub), Exemplar: e}
+			if isCount(v) {
+				bucket.CumulativeCount = proto.Uint64(uint64(v))
+			} else {
+				bucket.CumulativeCountFloat = proto.Float64(v)
+			}
+			m.Histogram.Bucket = append(m.Histogram.Bucket, bucket)
+		}
+	default:
+		m.Untyped = &dto.Untyped{Value: proto.Float64(v)}
+	}
+	return nil
+}
+
+// isCount returns whether v can be represented as an integer count.
+func isCount(v float64) bool {
+	return v >= 0 && v < math.MaxUint64 && v == math.Trunc(v)
+}
+
+func makeDTOExemplarFromModel(e exemplar.Exemplar) *dto.Exemplar {
+	result := &dto.Exemplar{Value: proto.Float64(e.Value)}
+	e.Labels.Range(func(l labels.Label) {
+		result.Label = append(result.Label, &dto.LabelPair{Name: proto.String(l.Name), Value: proto.String(l.Value)})
+	})
+	if e.HasTs {
+		result.Timestamp = timestamppb.New(time.UnixMilli(e.Ts))
+	}
+	return result
+}
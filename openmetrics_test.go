@@ -0,0 +1,218 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const openMetricsInput = `# TYPE requests counter
+# HELP requests Total requests.
+requests_total{code="200"} 10 # {trace_id="abc"} 1.0 1520879607.789
+requests_created{code="200"} 1520430000.123
+# TYPE latency_seconds histogram
+# UNIT latency_seconds seconds
+latency_seconds_bucket{le="0.5"} 3
+latency_seconds_bucket{le="+Inf"} 5
+latency_seconds_count 5
+latency_seconds_sum 2.5
+# TYPE rpc summary
+rpc{quantile="0.5"} 0.25
+rpc_count 7
+rpc_sum 3
+# TYPE build info
+build_info{version="1.0"} 1
+# TYPE temperature gauge
+temperature 21.5 1520879607.789
+untyped_sample 4
+# EOF
+`
+
+var openMetricsOutput = []*dto.MetricFamily{
+	{
+		Name: proto.String("requests_total"),
+		Help: proto.String("Total requests."),
+		Type: dto.MetricType_COUNTER.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Label: []*dto.LabelPair{createLabelPair("code", "200")},
+				Counter: &dto.Counter{
+					Value: floatPtr(10),
+					Exemplar: &dto.Exemplar{
+						Label:     []*dto.LabelPair{createLabelPair("trace_id", "abc")},
+						Value:     floatPtr(1),
+						Timestamp: &timestamppb.Timestamp{Seconds: 1520879607, Nanos: 789000000},
+					},
+					CreatedTimestamp: &timestamppb.Timestamp{Seconds: 1520430000, Nanos: 123000000},
+				},
+			},
+		},
+	},
+	{
+		Name: proto.String("latency_seconds"),
+		Unit: proto.String("seconds"),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Histogram: &dto.Histogram{
+					SampleCount: uintPtr(5),
+					SampleSum:   floatPtr(2.5),
+					Bucket: []*dto.Bucket{
+						createBucket(0.5, 3),
+						createBucket(math.Inf(+1), 5),
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: proto.String("rpc"),
+		Type: dto.MetricType_SUMMARY.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Summary: &dto.Summary{
+					SampleCount: uintPtr(7),
+					SampleSum:   floatPtr(3),
+					Quantile:    []*dto.Quantile{createQuantile(0.5, 0.25)},
+				},
+			},
+		},
+	},
+	{
+		Name: proto.String("build_info"),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Label: []*dto.LabelPair{createLabelPair("version", "1.0")},
+				Gauge: &dto.Gauge{Value: floatPtr(1)},
+			},
+		},
+	},
+	{
+		Name: proto.String("temperature"),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Gauge:       &dto.Gauge{Value: floatPtr(21.5)},
+				TimestampMs: int64Ptr(1520879607789),
+			},
+		},
+	},
+	{
+		Name: proto.String("untyped_sample"),
+		Type: dto.MetricType_UNTYPED.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Untyped: &dto.Untyped{Value: floatPtr(4)},
+			},
+		},
+	},
+}
+
+func checkOpenMetricsOutput(t *testing.T, mfChan <-chan *dto.MetricFamily) {
+	t.Helper()
+	i := 0
+	for mf := range mfChan {
+		if i >= len(openMetricsOutput) {
+			t.Errorf("unexpected metric family %s", mf.GetName())
+			continue
+		}
+		if !proto.Equal(openMetricsOutput[i], mf) {
+			t.Errorf("metric family %d does not match:\nexpected:\n%s\n\nactual:\n%s",
+				i, spew.Sdump(openMetricsOutput[i]), spew.Sdump(mf))
+		}
+		i++
+	}
+	if i != len(openMetricsOutput) {
+		t.Errorf("expected %d metric families, got %d", len(openMetricsOutput), i)
+	}
+}
+
+func TestParseReaderOpenMetrics(t *testing.T) {
+	mfChan := make(chan *dto.MetricFamily, len(openMetricsOutput)+1)
+	if err := ParseReader(strings.NewReader(openMetricsInput), mfChan); err != nil {
+		t.Fatal(err)
+	}
+	checkOpenMetricsOutput(t, mfChan)
+}
+
+func TestParseResponseOpenMetrics(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": {"application/openmetrics-text; version=1.0.0; charset=utf-8"}},
+		Body:   io.NopCloser(strings.NewReader(openMetricsInput)),
+	}
+	mfChan := make(chan *dto.MetricFamily, len(openMetricsOutput)+1)
+	if err := ParseResponse(resp, mfChan); err != nil {
+		t.Fatal(err)
+	}
+	checkOpenMetricsOutput(t, mfChan)
+}
+
+func TestParseOpenMetricsErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"missing EOF":      "# TYPE a gauge\na 1\n",
+		"invalid quantile": "# TYPE a summary\na{quantile=\"x\"} 1\n# EOF\n",
+		"invalid value":    "# TYPE a gauge\na one\n# EOF\n",
+	} {
+		resp := &http.Response{
+			Header: http.Header{"Content-Type": {openMetricsType}},
+			Body:   io.NopCloser(strings.NewReader(in)),
+		}
+		mfChan := make(chan *dto.MetricFamily, 10)
+		if err := ParseResponse(resp, mfChan); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
+
+func TestNewFamilyOpenMetricsGaugeHistogram(t *testing.T) {
+	mfChan := make(chan *dto.MetricFamily, 10)
+	in := `# TYPE queue_size gaugehistogram
+queue_size_bucket{le="1"} 2
+queue_size_bucket{le="10"} 5
+queue_size_bucket{le="+Inf"} 6
+queue_size_gcount 6
+queue_size_gsum 23.5
+# EOF
+`
+	if err := ParseReader(strings.NewReader(in), mfChan); err != nil {
+		t.Fatal(err)
+	}
+	expected := &Family{
+		Name: "queue_size",
+		Type: "GAUGE_HISTOGRAM",
+		Metrics: []any{
+			Histogram{
+				Labels:  map[string]string{},
+				Buckets: map[string]string{"1": "2", "10": "5", "+Inf": "6"},
+				Count:   "6",
+				Sum:     "23.5",
+			},
+		},
+	}
+	f := NewFamily(<-mfChan)
+	if !reflect.DeepEqual(expected, f) {
+		t.Errorf("expected\n%s\ngot\n%s", spew.Sdump(expected), spew.Sdump(f))
+	}
+}
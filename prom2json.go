@@ -14,69 +14,206 @@
 package prom2json
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"slices"
 
 	"github.com/matttproud/golang_protobuf_extensions/pbutil"
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/prom2json/histogram"
 )
 
-const (
-	acceptHeader = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=1.0.0;q=0.2,text/plain;version=0.0.4;q=0.1`
-	// acceptHeaderTemplate takes the escaping scheme as its single
-	// parameter. Note that we even add the parameter to
-	// text/plain;version=0.0.4. This version officially does not support
-	// escaping scheme selection, but some targets implement it anyway, so
-	// no harm in trying.
-	acceptHeaderTemplate = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;escaping=%[1]s;q=0.7,text/plain;version=1.0.0;escaping=%[1]s;q=0.2,text/plain;version=0.0.4;escaping=%[1]s;q=0.1`
-)
-
 // Family mirrors the MetricFamily proto message.
 type Family struct {
 	//Time    time.Time
 	Name    string `json:"name"`
 	Help    string `json:"help"`
+	Unit    string `json:"unit,omitempty"`
 	Type    string `json:"type"`
-	Metrics []any  `json:"metrics,omitempty"` // Either metric or summary.
+	Metrics []any  `json:"metrics,omitempty"` // Metric, Summary, or Histogram.
 }
 
 // Metric is for all "single value" metrics, i.e. Counter, Gauge, and Untyped.
 type Metric struct {
 	Labels      map[string]string `json:"labels,omitempty"`
 	TimestampMs string            `json:"timestamp_ms,omitempty"`
-	Value       string            `json:"value"`
+	// CreatedTimestampMs is only set for a Counter.
+	CreatedTimestampMs string `json:"created_timestamp_ms,omitempty"`
+	Value              string `json:"value"`
+	// Exemplars contains at most one exemplar, and only for a Counter.
+	Exemplars []Exemplar `json:"exemplars,omitempty"`
 }
 
 // Summary mirrors the Summary proto message.
 type Summary struct {
-	Labels      map[string]string `json:"labels,omitempty"`
-	TimestampMs string            `json:"timestamp_ms,omitempty"`
-	Quantiles   map[string]string `json:"quantiles,omitempty"`
-	Count       string            `json:"count"`
-	Sum         string            `json:"sum"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	TimestampMs        string            `json:"timestamp_ms,omitempty"`
+	CreatedTimestampMs string            `json:"created_timestamp_ms,omitempty"`
+	Quantiles          map[string]string `json:"quantiles,omitempty"`
+	Count              string            `json:"count"`
+	Sum                string            `json:"sum"`
 }
 
 // Histogram mirrors the Histogram proto message.
 type Histogram struct {
+	Labels             map[string]string `json:"labels,omitempty"`
+	TimestampMs        string            `json:"timestamp_ms,omitempty"`
+	CreatedTimestampMs string            `json:"created_timestamp_ms,omitempty"`
+	// Buckets is a map[string]string, mapping upper bounds to cumulative
+	// counts, for a classic histogram. For a native histogram, it is a
+	// [][]any as created by histogram.BucketsAsJson if created by
+	// NewFamily, or a []NativeBucket if decoded from JSON. It is nil for a
+	// native histogram represented by Raw only.
+	Buckets any `json:"buckets,omitempty"`
+	// NativeBuckets is only set for a histogram with both classic buckets
+	// and native spans created with the ClassicAndNative HistogramBuckets,
+	// see NewFamilyWithOptions. Buckets then holds the classic buckets,
+	// and NativeBuckets the native ones, in the same way as Buckets would.
+	NativeBuckets any `json:"native_buckets,omitempty"`
+	// Raw is only set for a native histogram created with the NativeRaw or
+	// NativeBucketsAndRaw format, see NewFamilyWithOptions.
+	Raw   *RawNativeHistogram `json:"raw,omitempty"`
+	Count string              `json:"count"`
+	Sum   string              `json:"sum"`
+	// Quantiles and Mean are only set by AddHistogramStats.
+	Quantiles map[string]string `json:"quantiles,omitempty"`
+	Mean      string            `json:"mean,omitempty"`
+	Exemplars []Exemplar        `json:"exemplars,omitempty"`
+	// NativeExemplars is set like NativeBuckets. Exemplars then holds the
+	// exemplars of the classic buckets, and NativeExemplars those of the
+	// native histogram.
+	NativeExemplars []Exemplar `json:"native_exemplars,omitempty"`
+}
+
+// Exemplar mirrors the Exemplar proto message. For an exemplar of a histogram,
+// Bucket is the upper bound of the bucket the exemplar belongs to, formatted
+// in the same way as in the Buckets of the Histogram. It is empty if there is
+// no such bucket (and always for an exemplar of a Counter).
+type Exemplar struct {
 	Labels      map[string]string `json:"labels,omitempty"`
 	TimestampMs string            `json:"timestamp_ms,omitempty"`
-	Buckets     any               `json:"buckets,omitempty"`
-	Count       string            `json:"count"`
-	Sum         string            `json:"sum"`
+	Value       string            `json:"value"`
+	Bucket      string            `json:"bucket,omitempty"`
+}
+
+// NativeBucket is a bucket of a native histogram. Like in the Prometheus query
+// API, it is encoded in JSON as an array of the boundary rule, the lower
+// boundary, the upper boundary, and the count.
+type NativeBucket struct {
+	Boundaries   uint64
+	Lower, Upper string
+	Count        string
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b NativeBucket) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{b.Boundaries, b.Lower, b.Upper, b.Count})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *NativeBucket) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 4 {
+		return fmt.Errorf("native bucket has %d elements, expected 4", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &b.Boundaries); err != nil {
+		return err
+	}
+	for i, s := range []*string{&b.Lower, &b.Upper, &b.Count} {
+		if err := json.Unmarshal(raw[i+1], s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interval returns the interval of the bucket in mathematical notation, e.g.
+// "(0.5,1]".
+func (b NativeBucket) interval() string {
+	left, right := "(", "]"
+	if b.Boundaries == 1 || b.Boundaries == 3 {
+		left = "["
+	}
+	if b.Boundaries == 1 || b.Boundaries == 2 {
+		right = ")"
+	}
+	return left + b.Lower + "," + b.Upper + right
+}
+
+// nativeBuckets returns the native buckets of a Histogram as NativeBuckets,
+// derived from Raw if there are no Buckets or NativeBuckets. native is false
+// for a classic Histogram.
+func (h Histogram) nativeBuckets() (buckets []NativeBucket, native bool) {
+	nb := h.Buckets
+	if _, classic := nb.(map[string]string); classic {
+		nb = h.NativeBuckets
+	}
+	switch b := nb.(type) {
+	case [][]any:
+		buckets, _ = newNativeBuckets(b)
+		return buckets, true
+	case []NativeBucket:
+		return b, true
+	case nil:
+		if h.Raw != nil {
+			buckets, _ = h.Raw.nativeBuckets(h.Count, h.Sum)
+			return buckets, true
+		}
+	}
+	return nil, false
+}
+
+// HistogramBuckets selects the buckets of a histogram with both classic
+// buckets and native spans. Histograms with only one kind of buckets are not
+// affected.
+type HistogramBuckets int
+
+// The supported HistogramBuckets.
+const (
+	// NativeOnly keeps only the native buckets. This is what NewFamily
+	// does.
+	NativeOnly HistogramBuckets = iota
+	// ClassicOnly keeps only the classic buckets.
+	ClassicOnly
+	// ClassicAndNative keeps both, the classic buckets in the Buckets and
+	// the native ones in the NativeBuckets of a Histogram.
+	ClassicAndNative
+)
+
+// FamilyOptions determine how NewFamilyWithOptions represents histograms. The
+// zero value represents them like NewFamily does.
+type FamilyOptions struct {
+	NativeFormat     NativeHistogramFormat
+	HistogramBuckets HistogramBuckets
 }
 
 // NewFamily consumes a MetricFamily and transforms it to the local Family type.
 func NewFamily(dtoMF *dto.MetricFamily) *Family {
+	return NewFamilyWithOptions(dtoMF, FamilyOptions{})
+}
+
+// NewFamilyWithOptions works like NewFamily, but represents histograms as
+// determined by the provided options.
+func NewFamilyWithOptions(dtoMF *dto.MetricFamily, opts FamilyOptions) *Family {
 	mf := &Family{
 		//Time:    time.Now(),
 		Name:    dtoMF.GetName(),
 		Help:    dtoMF.GetHelp(),
+		Unit:    dtoMF.GetUnit(),
 		Type:    dtoMF.GetType().String(),
 		Metrics: make([]any, len(dtoMF.Metric)),
 	}
@@ -84,19 +221,22 @@ func NewFamily(dtoMF *dto.MetricFamily) *Family {
 		switch dtoMF.GetType() {
 		case dto.MetricType_SUMMARY:
 			mf.Metrics[i] = Summary{
-				Labels:      makeLabels(m),
-				TimestampMs: makeTimestamp(m),
-				Quantiles:   makeQuantiles(m),
-				Count:       fmt.Sprint(m.GetSummary().GetSampleCount()),
-				Sum:         fmt.Sprint(m.GetSummary().GetSampleSum()),
+				Labels:             makeLabels(m),
+				TimestampMs:        makeTimestamp(m),
+				CreatedTimestampMs: makeTimestampMs(m.GetSummary().GetCreatedTimestamp()),
+				Quantiles:          makeQuantiles(m),
+				Count:              fmt.Sprint(m.GetSummary().GetSampleCount()),
+				Sum:                fmt.Sprint(m.GetSummary().GetSampleSum()),
 			}
-		case dto.MetricType_HISTOGRAM:
-			mf.Metrics[i] = makeHistogram(m)
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			mf.Metrics[i] = makeHistogram(m, opts)
 		default:
 			mf.Metrics[i] = Metric{
-				Labels:      makeLabels(m),
-				TimestampMs: makeTimestamp(m),
-				Value:       fmt.Sprint(getValue(m)),
+				Labels:             makeLabels(m),
+				TimestampMs:        makeTimestamp(m),
+				CreatedTimestampMs: makeTimestampMs(m.GetCounter().GetCreatedTimestamp()),
+				Value:              fmt.Sprint(getValue(m)),
+				Exemplars:          makeCounterExemplars(m),
 			}
 		}
 	}
@@ -116,26 +256,48 @@ func getValue(m *dto.Metric) float64 {
 	}
 }
 
-func makeHistogram(m *dto.Metric) Histogram {
+func makeHistogram(m *dto.Metric, opts FamilyOptions) Histogram {
 	dtoH := m.GetHistogram()
 	hist := Histogram{
-		Labels:      makeLabels(m),
-		TimestampMs: makeTimestamp(m),
-		Sum:         fmt.Sprint(dtoH.GetSampleSum()),
+		Labels:             makeLabels(m),
+		TimestampMs:        makeTimestamp(m),
+		CreatedTimestampMs: makeTimestampMs(dtoH.GetCreatedTimestamp()),
+		Sum:                fmt.Sprint(dtoH.GetSampleSum()),
 	}
-	// A native histogram is marked by at least one span.
-	if len(dtoH.GetNegativeSpan())+len(dtoH.GetPositiveSpan()) > 0 {
+	// A native histogram is marked by at least one span. It may have
+	// classic buckets, too.
+	native := len(dtoH.GetNegativeSpan())+len(dtoH.GetPositiveSpan()) > 0
+	classic := !native || len(dtoH.GetBucket()) > 0 && opts.HistogramBuckets != NativeOnly
+	if classic && opts.HistogramBuckets == ClassicOnly {
+		native = false
+	}
+	if native {
 		h, fh := histogram.NewModelHistogram(dtoH)
 		if h == nil {
 			// float histogram
-			hist.Buckets = histogram.BucketsAsJson[float64](histogram.GetAPIFloatBuckets(fh))
+			buckets := histogram.GetAPIFloatBuckets(fh)
+			hist.Buckets = histogram.BucketsAsJson[float64](buckets)
 			hist.Count = fmt.Sprint(fh.Count)
+			hist.Exemplars = makeNativeExemplars(dtoH, buckets)
 		} else {
-			hist.Buckets = histogram.BucketsAsJson[uint64](histogram.GetAPIBuckets(h))
+			buckets := histogram.GetAPIBuckets(h)
+			hist.Buckets = histogram.BucketsAsJson[uint64](buckets)
 			hist.Count = fmt.Sprint(h.Count)
+			hist.Exemplars = makeNativeExemplars(dtoH, buckets)
+		}
+		if opts.NativeFormat != NativeBuckets {
+			hist.Raw = newRawNativeHistogram(h, fh)
+		}
+		if opts.NativeFormat == NativeRaw {
+			hist.Buckets = nil
+		}
+	}
+	if classic {
+		if native {
+			hist.NativeBuckets, hist.NativeExemplars = hist.Buckets, hist.Exemplars
 		}
-	} else {
 		hist.Buckets = makeBuckets(m)
+		hist.Exemplars = makeBucketExemplars(m)
 		if count := dtoH.GetSampleCountFloat(); count > 0 {
 			hist.Count = fmt.Sprint(count)
 		} else {
@@ -146,13 +308,60 @@ func makeHistogram(m *dto.Metric) Histogram {
 }
 
 func makeLabels(m *dto.Metric) map[string]string {
+	return makeLabelMap(m.Label)
+}
+
+func makeLabelMap(lps []*dto.LabelPair) map[string]string {
 	result := map[string]string{}
-	for _, lp := range m.Label {
+	for _, lp := range lps {
 		result[lp.GetName()] = lp.GetValue()
 	}
 	return result
 }
 
+func makeExemplar(e *dto.Exemplar, bucket string) Exemplar {
+	return Exemplar{
+		Labels:      makeLabelMap(e.Label),
+		TimestampMs: makeTimestampMs(e.GetTimestamp()),
+		Value:       fmt.Sprint(e.GetValue()),
+		Bucket:      bucket,
+	}
+}
+
+func makeCounterExemplars(m *dto.Metric) []Exemplar {
+	if e := m.GetCounter().GetExemplar(); e != nil {
+		return []Exemplar{makeExemplar(e, "")}
+	}
+	return nil
+}
+
+func makeBucketExemplars(m *dto.Metric) []Exemplar {
+	var result []Exemplar
+	for _, b := range m.GetHistogram().Bucket {
+		if e := b.GetExemplar(); e != nil {
+			result = append(result, makeExemplar(e, fmt.Sprint(b.GetUpperBound())))
+		}
+	}
+	return result
+}
+
+// makeNativeExemplars assigns each exemplar of a native histogram to the bucket
+// its value falls into.
+func makeNativeExemplars[BC uint64 | float64](dtoH *dto.Histogram, buckets []histogram.APIBucket[BC]) []Exemplar {
+	var result []Exemplar
+	for _, e := range dtoH.GetExemplars() {
+		bucket := ""
+		for _, b := range buckets {
+			if b.Contains(e.GetValue()) {
+				bucket = fmt.Sprint(b.Upper)
+				break
+			}
+		}
+		result = append(result, makeExemplar(e, bucket))
+	}
+	return result
+}
+
 func makeTimestamp(m *dto.Metric) string {
 	if m.TimestampMs == nil {
 		return ""
@@ -160,6 +369,13 @@ func makeTimestamp(m *dto.Metric) string {
 	return fmt.Sprint(m.GetTimestampMs())
 }
 
+func makeTimestampMs(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return fmt.Sprint(ts.AsTime().UnixMilli())
+}
+
 func makeQuantiles(m *dto.Metric) map[string]string {
 	result := map[string]string{}
 	for _, q := range m.GetSummary().Quantile {
@@ -184,59 +400,66 @@ func makeBuckets(m *dto.Metric) map[string]string {
 // into MetricFamily proto messages, and sends them to the provided channel. It
 // returns after all MetricFamilies have been sent. The provided transport
 // may be nil (in which case the default Transport is used).
+//
+// FetchMetricFamilies is a shortcut for Fetcher.Fetch, which offers more
+// options.
 func FetchMetricFamilies(url string, ch chan<- *dto.MetricFamily, transport http.RoundTripper) error {
-	return FetchMetricFamiliesWithEscapingScheme(url, ch, transport, "")
+	return (&Fetcher{Transport: transport}).Fetch(context.Background(), url, ch)
 }
 
 // FetchMetricFamiliesWithEscapingScheme works like FetchMetricFamilies but adds
 // the provided string as the value of the additional 'escaping' parameter in
 // the accept header.
 func FetchMetricFamiliesWithEscapingScheme(url string, ch chan<- *dto.MetricFamily, transport http.RoundTripper, escapingScheme string) error {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		close(ch)
-		return fmt.Errorf("creating GET request for URL %q failed: %w", url, err)
-	}
-	if escapingScheme != "" {
-		req.Header.Add("Accept", fmt.Sprintf(acceptHeaderTemplate, escapingScheme))
-	} else {
-		req.Header.Add("Accept", acceptHeader)
-	}
-	client := http.Client{Transport: transport}
-	resp, err := client.Do(req)
-	if err != nil {
-		close(ch)
-		return fmt.Errorf("executing GET request for URL %q failed: %w", url, err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		close(ch)
-		return fmt.Errorf("GET request for URL %q returned HTTP status %s", url, resp.Status)
-	}
-	return ParseResponse(resp, ch)
+	return FetchMetricFamiliesContext(context.Background(), url, ch, transport, escapingScheme)
+}
+
+// FetchMetricFamiliesContext works like FetchMetricFamiliesWithEscapingScheme
+// but gives up once the provided Context is canceled or its deadline is
+// exceeded, be it while sending the request, while reading and parsing the
+// response, or while waiting to send MetricFamilies to the channel. The channel
+// is closed in any case. If the Context has a deadline, the time left until
+// then is sent in the X-Prometheus-Scrape-Timeout-Seconds header, as the
+// Prometheus server does, so that the target can adjust to it.
+func FetchMetricFamiliesContext(ctx context.Context, url string, ch chan<- *dto.MetricFamily, transport http.RoundTripper, escapingScheme string) error {
+	return (&Fetcher{Transport: transport, EscapingScheme: escapingScheme}).Fetch(ctx, url, ch)
 }
 
 // ParseResponse consumes an http.Response and pushes it to the MetricFamily
 // channel. It returns when all MetricFamilies are parsed and put on the
 // channel.
 func ParseResponse(resp *http.Response, ch chan<- *dto.MetricFamily) error {
-	mediatype, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
-	if err == nil && mediatype == "application/vnd.google.protobuf" &&
+	return parseResponse(resp.Header.Get("Content-Type"), resp.Body, sink{ctx: context.Background(), ch: ch})
+}
+
+func parseResponse(contentType string, body io.Reader, s sink) error {
+	mediatype, params, err := mime.ParseMediaType(contentType)
+	switch {
+	case err == nil && mediatype == "application/vnd.google.protobuf" &&
 		params["encoding"] == "delimited" &&
-		params["proto"] == "io.prometheus.client.MetricFamily" {
-		defer close(ch)
+		params["proto"] == "io.prometheus.client.MetricFamily":
+		defer close(s.ch)
 		for {
 			mf := &dto.MetricFamily{}
-			if _, err = pbutil.ReadDelimited(resp.Body, mf); err != nil {
+			if _, err = pbutil.ReadDelimited(body, mf); err != nil {
 				if err == io.EOF {
 					break
 				}
 				return fmt.Errorf("reading metric family protocol buffer failed: %w", err)
 			}
-			ch <- mf
+			if err := s.send(mf); err != nil {
+				return err
+			}
 		}
-	} else {
-		if err := ParseReader(resp.Body, ch); err != nil {
+	case err == nil && mediatype == openMetricsType:
+		defer close(s.ch)
+		in, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("reading OpenMetrics format failed: %w", err)
+		}
+		return parseOpenMetrics(in, s)
+	default:
+		if err := parseReader(body, s); err != nil {
 			return err
 		}
 	}
@@ -245,30 +468,183 @@ func ParseResponse(resp *http.Response, ch chan<- *dto.MetricFamily) error {
 
 // ParseReader consumes an io.Reader and pushes it to the MetricFamily
 // channel. It returns when all MetricFamilies are parsed and put on the
-// channel.
+// channel. The input is parsed as the OpenMetrics text format if it ends with
+// the "# EOF" line mandated by OpenMetrics and as the classic Prometheus text
+// format otherwise.
 func ParseReader(in io.Reader, ch chan<- *dto.MetricFamily) error {
-	defer close(ch)
+	return parseReader(in, sink{ctx: context.Background(), ch: ch})
+}
+
+func parseReader(in io.Reader, s sink) error {
+	defer close(s.ch)
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("reading input failed: %w", err)
+	}
+	if isOpenMetrics(b) {
+		return parseOpenMetrics(b, s)
+	}
 	// We could do further content-type checks here, but the
 	// fallback for now will anyway be the text format
 	// version 0.0.4, so just go for it and see if it works.
-	parser := expfmt.NewTextParser(model.UTF8Validation)
-	metricFamilies, err := parser.TextToMetricFamilies(in)
+	scheme := s.scheme
+	if scheme == model.UnsetValidation {
+		scheme = model.UTF8Validation
+	}
+	parser := expfmt.NewTextParser(scheme)
+	metricFamilies, err := parser.TextToMetricFamilies(bytes.NewReader(b))
 	if err != nil {
 		return fmt.Errorf("reading text format failed: %v", err)
 	}
 	for _, mf := range metricFamilies {
-		ch <- mf
+		if err := s.send(mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sink is where parsed MetricFamilies go.
+type sink struct {
+	ctx context.Context
+	ch  chan<- *dto.MetricFamily
+	// If set, scheme is used to validate metric and label names.
+	scheme model.ValidationScheme
+}
+
+// send sends mf to the channel unless the names in mf are invalid or the
+// Context is done before that.
+func (s sink) send(mf *dto.MetricFamily) error {
+	if s.scheme != model.UnsetValidation {
+		if err := validateNames(mf, s.scheme); err != nil {
+			return err
+		}
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case s.ch <- mf:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func validateNames(mf *dto.MetricFamily, scheme model.ValidationScheme) error {
+	if !scheme.IsValidMetricName(mf.GetName()) {
+		return fmt.Errorf("invalid metric name %q", mf.GetName())
+	}
+	for _, m := range mf.Metric {
+		for _, lp := range m.Label {
+			if !scheme.IsValidLabelName(lp.GetName()) {
+				return fmt.Errorf("invalid label name %q in metric %q", lp.GetName(), mf.GetName())
+			}
+		}
 	}
 	return nil
 }
 
 // AddLabel allows to add key/value labels to an already existing Family.
 func (f *Family) AddLabel(key, val string) {
-	for i, item := range f.Metrics {
+	f.updateLabels(func(labels map[string]string) bool {
+		labels[key] = val
+		return true
+	})
+}
+
+// RemoveLabel removes the label with the provided name from all Metrics of the
+// Family.
+func (f *Family) RemoveLabel(key string) {
+	f.updateLabels(func(labels map[string]string) bool {
+		delete(labels, key)
+		return true
+	})
+}
+
+// RenameLabel renames the label with the name oldKey to newKey in all Metrics
+// of the Family that have such a label. An existing label named newKey is
+// overwritten.
+func (f *Family) RenameLabel(oldKey, newKey string) {
+	f.updateLabels(func(labels map[string]string) bool {
+		if val, ok := labels[oldKey]; ok {
+			delete(labels, oldKey)
+			labels[newKey] = val
+		}
+		return true
+	})
+}
+
+// Relabel applies the provided relabel configs to the labels of each Metric of
+// the Family, following the semantics of the relabel_config of Prometheus.
+// The name of the Family is visible to the relabel configs as the "__name__"
+// label, but changing it has no effect. Metrics dropped by a relabel config are
+// removed from the Family. As when unmarshaling a relabel config from YAML, an
+// empty Action, Separator, Regex, or Replacement is replaced by its default
+// from relabel.DefaultRelabelConfig. If the NameValidationScheme is unset,
+// UTF-8 validation is used. Relabel returns an error, leaving the Family
+// unchanged, if a relabel config is invalid.
+func (f *Family) Relabel(cfgs ...*relabel.Config) error {
+	cfgs = slices.Clone(cfgs)
+	for i, cfg := range cfgs {
+		c := *cfg
+		if c.Action == "" {
+			c.Action = relabel.DefaultRelabelConfig.Action
+		}
+		if c.Separator == "" {
+			c.Separator = relabel.DefaultRelabelConfig.Separator
+		}
+		if c.Regex.Regexp == nil {
+			c.Regex = relabel.DefaultRelabelConfig.Regex
+		}
+		if c.Replacement == "" {
+			c.Replacement = relabel.DefaultRelabelConfig.Replacement
+		}
+		if err := c.Validate(model.UTF8Validation); err != nil {
+			return fmt.Errorf("invalid relabel config %d: %w", i, err)
+		}
+		cfgs[i] = &c
+	}
+	f.updateLabels(func(lbls map[string]string) bool {
+		b := labels.NewBuilder(labels.FromMap(lbls)).Set(model.MetricNameLabel, f.Name)
+		if !relabel.ProcessBuilder(b, cfgs...) {
+			return false
+		}
+		clear(lbls)
+		b.Del(model.MetricNameLabel).Labels().Range(func(l labels.Label) {
+			lbls[l.Name] = l.Value
+		})
+		return true
+	})
+	return nil
+}
+
+// updateLabels calls update with the labels of each Metric of the Family, which
+// update may modify in place. If update returns false, the Metric is removed
+// from the Family.
+func (f *Family) updateLabels(update func(labels map[string]string) bool) {
+	kept := f.Metrics[:0]
+	for _, item := range f.Metrics {
 		switch m := item.(type) {
 		case Metric:
-			m.Labels[key] = val
-			f.Metrics[i] = m
+			m.Labels = nonNilMap(m.Labels)
+			if update(m.Labels) {
+				kept = append(kept, m)
+			}
+		case Summary:
+			m.Labels = nonNilMap(m.Labels)
+			if update(m.Labels) {
+				kept = append(kept, m)
+			}
+		case Histogram:
+			m.Labels = nonNilMap(m.Labels)
+			if update(m.Labels) {
+				kept = append(kept, m)
+			}
+		default:
+			kept = append(kept, item)
 		}
 	}
+	clear(f.Metrics[len(kept):])
+	f.Metrics = kept
 }
@@ -14,12 +14,24 @@
 package prom2json
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type testCase struct {
@@ -257,6 +269,46 @@ var tcs = []testCase{
 			},
 		},
 	},
+	testCase{
+		name: "test gauge histograms",
+		mFamily: &dto.MetricFamily{
+			Name: strPtr("gauge_histogram1"),
+			Type: metricTypePtr(dto.MetricType_GAUGE_HISTOGRAM),
+			Metric: []*dto.Metric{
+				&dto.Metric{
+					Label: []*dto.LabelPair{
+						createLabelPair("tag1", "abc"),
+					},
+					Histogram: &dto.Histogram{
+						SampleCount: uintPtr(6),
+						SampleSum:   floatPtr(23.5),
+						Bucket: []*dto.Bucket{
+							createBucket(1, 2),
+							createBucket(10, 5),
+						},
+					},
+				},
+			},
+		},
+		output: &Family{
+			Name: "gauge_histogram1",
+			Help: "",
+			Type: "GAUGE_HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels: map[string]string{
+						"tag1": "abc",
+					},
+					Buckets: map[string]string{
+						"1":  "2",
+						"10": "5",
+					},
+					Count: "6",
+					Sum:   "23.5",
+				},
+			},
+		},
+	},
 	testCase{
 		name: "test native histograms",
 		mFamily: &dto.MetricFamily{
@@ -391,6 +443,163 @@ var tcs = []testCase{
 			},
 		},
 	},
+	testCase{
+		name: "test created timestamps and unit",
+		mFamily: &dto.MetricFamily{
+			Name: strPtr("summary2"),
+			Unit: strPtr("seconds"),
+			Type: metricTypePtr(dto.MetricType_SUMMARY),
+			Metric: []*dto.Metric{
+				&dto.Metric{
+					Summary: &dto.Summary{
+						SampleCount:      uintPtr(1),
+						SampleSum:        floatPtr(2),
+						CreatedTimestamp: timestamppb.New(time.UnixMilli(1520430000123)),
+					},
+				},
+			},
+		},
+		output: &Family{
+			Name: "summary2",
+			Help: "",
+			Unit: "seconds",
+			Type: "SUMMARY",
+			Metrics: []any{
+				Summary{
+					Labels:             map[string]string{},
+					CreatedTimestampMs: "1520430000123",
+					Quantiles:          map[string]string{},
+					Count:              "1",
+					Sum:                "2",
+				},
+			},
+		},
+	},
+	testCase{
+		name: "test exemplars",
+		mFamily: &dto.MetricFamily{
+			Name: strPtr("counter2"),
+			Type: metricTypePtr(dto.MetricType_COUNTER),
+			Metric: []*dto.Metric{
+				&dto.Metric{
+					Counter: &dto.Counter{
+						Value:            floatPtr(17),
+						Exemplar:         createExemplar(3, 1520879607789, "trace_id", "abc"),
+						CreatedTimestamp: timestamppb.New(time.UnixMilli(1520430000123)),
+					},
+				},
+			},
+		},
+		output: &Family{
+			Name: "counter2",
+			Help: "",
+			Type: "COUNTER",
+			Metrics: []any{
+				Metric{
+					Labels:             map[string]string{},
+					CreatedTimestampMs: "1520430000123",
+					Value:              "17",
+					Exemplars: []Exemplar{
+						{
+							Labels:      map[string]string{"trace_id": "abc"},
+							TimestampMs: "1520879607789",
+							Value:       "3",
+						},
+					},
+				},
+			},
+		},
+	},
+	testCase{
+		name: "test histogram exemplars",
+		mFamily: &dto.MetricFamily{
+			Name: strPtr("histogram3"),
+			Type: metricTypePtr(dto.MetricType_HISTOGRAM),
+			Metric: []*dto.Metric{
+				&dto.Metric{
+					Histogram: &dto.Histogram{
+						SampleCount:      uintPtr(5),
+						SampleSum:        floatPtr(2),
+						CreatedTimestamp: timestamppb.New(time.UnixMilli(1520430000123)),
+						Bucket: []*dto.Bucket{
+							createBucket(0.5, 3),
+							{
+								UpperBound:      floatPtr(1),
+								CumulativeCount: uintPtr(5),
+								Exemplar:        createExemplar(0.7, 1520879607789, "trace_id", "def"),
+							},
+						},
+					},
+				},
+				&dto.Metric{
+					Histogram: &dto.Histogram{
+						SampleCount: uintPtr(10),
+						SampleSum:   floatPtr(123.45),
+						Schema:      int32Ptr(1),
+						PositiveSpan: []*dto.BucketSpan{
+							createBucketSpan(0, 3),
+							createBucketSpan(1, 1),
+						},
+						PositiveDelta: []int64{1, 2, 3, 4},
+						Exemplars: []*dto.Exemplar{
+							createExemplar(1.2, 1520879607789, "trace_id", "ghi"),
+							createExemplar(3, 1520879608000),
+						},
+					},
+				},
+			},
+		},
+		output: &Family{
+			Name: "histogram3",
+			Help: "",
+			Type: "HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels:             map[string]string{},
+					CreatedTimestampMs: "1520430000123",
+					Buckets: map[string]string{
+						"0.5": "3",
+						"1":   "5",
+					},
+					Count: "5",
+					Sum:   "2",
+					Exemplars: []Exemplar{
+						{
+							Labels:      map[string]string{"trace_id": "def"},
+							TimestampMs: "1520879607789",
+							Value:       "0.7",
+							Bucket:      "1",
+						},
+					},
+				},
+				Histogram{
+					Labels: map[string]string{},
+					Buckets: [][]any{
+						{uint64(0), "0.7071067811865475", "1", "1"},
+						{uint64(0), "1", "1.414213562373095", "3"},
+						{uint64(0), "1.414213562373095", "2", "6"},
+						{uint64(0), "2.82842712474619", "4", "10"},
+					},
+					Count: "10",
+					Sum:   "123.45",
+					Exemplars: []Exemplar{
+						{
+							Labels:      map[string]string{"trace_id": "ghi"},
+							TimestampMs: "1520879607789",
+							Value:       "1.2",
+							Bucket:      "1.414213562373095",
+						},
+						{
+							Labels:      map[string]string{},
+							TimestampMs: "1520879608000",
+							Value:       "3",
+							Bucket:      "4",
+						},
+					},
+				},
+			},
+		},
+	},
 }
 
 func TestConvertToMetricFamily(t *testing.T) {
@@ -403,6 +612,235 @@ func TestConvertToMetricFamily(t *testing.T) {
 	}
 }
 
+func TestHistogramBuckets(t *testing.T) {
+	mf := &dto.MetricFamily{
+		Name: strPtr("histogram"),
+		Type: metricTypePtr(dto.MetricType_HISTOGRAM),
+		Metric: []*dto.Metric{{
+			Histogram: &dto.Histogram{
+				SampleCount: uintPtr(4),
+				SampleSum:   floatPtr(5),
+				Bucket: []*dto.Bucket{
+					createBucket(1, 1),
+					createBucket(2, 3),
+				},
+				Schema:        int32Ptr(0),
+				ZeroThreshold: floatPtr(0),
+				ZeroCount:     uintPtr(0),
+				PositiveSpan:  []*dto.BucketSpan{createBucketSpan(0, 2)},
+				PositiveDelta: []int64{1, 2},
+				Exemplars:     []*dto.Exemplar{createExemplar(1.5, 1000, "id", "n")},
+			},
+		}},
+	}
+	mf.Metric[0].Histogram.Bucket[0].Exemplar = createExemplar(0.5, 1000, "id", "c")
+	classicBuckets := map[string]string{"1": "1", "2": "3"}
+	classicExemplars := []Exemplar{{Labels: map[string]string{"id": "c"}, TimestampMs: "1000", Value: "0.5", Bucket: "1"}}
+	nativeBuckets := [][]any{{uint64(0), "0.5", "1", "1"}, {uint64(0), "1", "2", "3"}}
+	nativeExemplars := []Exemplar{{Labels: map[string]string{"id": "n"}, TimestampMs: "1000", Value: "1.5", Bucket: "2"}}
+	for buckets, expected := range map[HistogramBuckets]Histogram{
+		NativeOnly: {
+			Labels:    map[string]string{},
+			Buckets:   nativeBuckets,
+			Count:     "4",
+			Sum:       "5",
+			Exemplars: nativeExemplars,
+		},
+		ClassicOnly: {
+			Labels:    map[string]string{},
+			Buckets:   classicBuckets,
+			Count:     "4",
+			Sum:       "5",
+			Exemplars: classicExemplars,
+		},
+		ClassicAndNative: {
+			Labels:          map[string]string{},
+			Buckets:         classicBuckets,
+			NativeBuckets:   nativeBuckets,
+			Count:           "4",
+			Sum:             "5",
+			Exemplars:       classicExemplars,
+			NativeExemplars: nativeExemplars,
+		},
+	} {
+		f := NewFamilyWithOptions(mf, FamilyOptions{HistogramBuckets: buckets})
+		if !reflect.DeepEqual(expected, f.Metrics[0]) {
+			t.Errorf("buckets %d: expected\n%s\ngot\n%s", buckets, spew.Sdump(expected), spew.Sdump(f.Metrics[0]))
+		}
+	}
+
+	// Both kinds of buckets and exemplars survive a round trip via JSON.
+	data, err := json.Marshal(NewFamilyWithOptions(mf, FamilyOptions{HistogramBuckets: ClassicAndNative}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f Family
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	output, err := NewMetricFamily(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(mf, output) {
+		t.Errorf("round trip via JSON failed:\nexpected:\n%s\n\nactual:\n%s", mf, output)
+	}
+}
+
+func newLabelTestFamily() *Family {
+	return &Family{
+		Name: "family",
+		Type: "HISTOGRAM",
+		Metrics: []any{
+			Metric{Labels: map[string]string{"a": "1"}, Value: "1"},
+			Summary{Labels: map[string]string{"a": "2"}, Count: "1", Sum: "1"},
+			Histogram{Labels: map[string]string{"a": "3", "b": "x"}, Count: "1", Sum: "1"},
+		},
+	}
+}
+
+func familyLabels(f *Family) []map[string]string {
+	var result []map[string]string
+	for _, item := range f.Metrics {
+		switch m := item.(type) {
+		case Metric:
+			result = append(result, m.Labels)
+		case Summary:
+			result = append(result, m.Labels)
+		case Histogram:
+			result = append(result, m.Labels)
+		}
+	}
+	return result
+}
+
+func TestLabelManipulation(t *testing.T) {
+	f := newLabelTestFamily()
+	f.AddLabel("instance", "host:9090")
+	f.RenameLabel("a", "c")
+	f.RemoveLabel("b")
+	expected := []map[string]string{
+		{"c": "1", "instance": "host:9090"},
+		{"c": "2", "instance": "host:9090"},
+		{"c": "3", "instance": "host:9090"},
+	}
+	if output := familyLabels(f); !reflect.DeepEqual(expected, output) {
+		t.Errorf("unexpected labels:\nexpected:\n%s\n\nactual:\n%s", spew.Sdump(expected), spew.Sdump(output))
+	}
+}
+
+func TestRelabel(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      relabel.Config
+		expected []map[string]string
+	}{
+		{
+			name: "replace",
+			cfg: relabel.Config{
+				Action: relabel.Replace, SourceLabels: model.LabelNames{"__name__", "a"}, Separator: ";",
+				Regex: relabel.MustNewRegexp("(.*);(.*)"), TargetLabel: "c", Replacement: "${1}_$2",
+			},
+			expected: []map[string]string{
+				{"a": "1", "c": "family_1"},
+				{"a": "2", "c": "family_2"},
+				{"a": "3", "b": "x", "c": "family_3"},
+			},
+		},
+		{
+			name: "keep",
+			cfg: relabel.Config{
+				Action: relabel.Keep, SourceLabels: model.LabelNames{"a"}, Separator: ";",
+				Regex: relabel.MustNewRegexp("[12]"),
+			},
+			expected: []map[string]string{{"a": "1"}, {"a": "2"}},
+		},
+		{
+			name: "drop",
+			cfg: relabel.Config{
+				Action: relabel.Drop, SourceLabels: model.LabelNames{"a"}, Separator: ";",
+				Regex: relabel.MustNewRegexp("[12]"),
+			},
+			expected: []map[string]string{{"a": "3", "b": "x"}},
+		},
+		{
+			name: "labelmap",
+			cfg: relabel.Config{
+				Action: relabel.LabelMap, Regex: relabel.MustNewRegexp("(b)"), Replacement: "mapped_$1",
+			},
+			expected: []map[string]string{
+				{"a": "1"},
+				{"a": "2"},
+				{"a": "3", "b": "x", "mapped_b": "x"},
+			},
+		},
+		{
+			name: "labeldrop",
+			cfg: relabel.Config{
+				Action: relabel.LabelDrop, Regex: relabel.MustNewRegexp("a"),
+			},
+			expected: []map[string]string{{}, {}, {"b": "x"}},
+		},
+		{
+			name: "defaults",
+			cfg: relabel.Config{
+				SourceLabels: model.LabelNames{"b"}, TargetLabel: "c",
+			},
+			expected: []map[string]string{
+				{"a": "1"},
+				{"a": "2"},
+				{"a": "3", "b": "x", "c": "x"},
+			},
+		},
+		{
+			name: "hashmod",
+			cfg: relabel.Config{
+				Action: relabel.HashMod, SourceLabels: model.LabelNames{"a"}, Separator: ";",
+				Modulus: 8, TargetLabel: "shard",
+			},
+			// The last 8 bytes of the MD5 hash of the value, modulo 8.
+			expected: []map[string]string{
+				{"a": "1", "shard": "3"},
+				{"a": "2", "shard": "4"},
+				{"a": "3", "b": "x", "shard": "3"},
+			},
+		},
+	} {
+		f := newLabelTestFamily()
+		if err := f.Relabel(&tc.cfg); err != nil {
+			t.Errorf("test case %s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if output := familyLabels(f); !reflect.DeepEqual(tc.expected, output) {
+			t.Errorf("test case %s: unexpected labels:\nexpected:\n%s\n\nactual:\n%s",
+				tc.name, spew.Sdump(tc.expected), spew.Sdump(output))
+		}
+	}
+}
+
+func TestRelabelErrors(t *testing.T) {
+	for name, cfg := range map[string]relabel.Config{
+		"hashmod without modulus": {
+			Action: relabel.HashMod, SourceLabels: model.LabelNames{"a"}, TargetLabel: "shard",
+		},
+		"replace without target label": {
+			Action: relabel.Replace, SourceLabels: model.LabelNames{"a"},
+		},
+		"unknown validation scheme": {
+			Action: relabel.Replace, SourceLabels: model.LabelNames{"a"}, TargetLabel: "b",
+			NameValidationScheme: model.ValidationScheme(42),
+		},
+	} {
+		f := newLabelTestFamily()
+		if err := f.Relabel(&cfg); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+		if !reflect.DeepEqual(newLabelTestFamily(), f) {
+			t.Errorf("%s: expected the family to be unchanged, got %s", name, spew.Sdump(f))
+		}
+	}
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -455,9 +893,77 @@ func createFloatBucket(bound float64, count float64) *dto.Bucket {
 	}
 }
 
+func createExemplar(v float64, tsMs int64, labels ...string) *dto.Exemplar {
+	e := &dto.Exemplar{
+		Value:     &v,
+		Timestamp: timestamppb.New(time.UnixMilli(tsMs)),
+	}
+	for i := 0; i < len(labels); i += 2 {
+		e.Label = append(e.Label, createLabelPair(labels[i], labels[i+1]))
+	}
+	return e
+}
+
 func createBucketSpan(offset int32, length uint32) *dto.BucketSpan {
 	return &dto.BucketSpan{
 		Offset: &offset,
 		Length: &length,
 	}
 }
+
+func TestFetchMetricFamiliesContext(t *testing.T) {
+	var timeoutHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeoutHeader = r.Header.Get(scrapeTimeoutHeader)
+		switch r.URL.Path {
+		case "/metrics":
+			fmt.Fprint(w, "# TYPE a gauge\na 1\n# TYPE b gauge\nb 2\n")
+		case "/slow":
+			// Send the headers, but never the body.
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	mfChan := make(chan *dto.MetricFamily, 10)
+	if err := FetchMetricFamiliesContext(ctx, server.URL+"/metrics", mfChan, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mfChan); n != 2 {
+		t.Errorf("expected 2 metric families, got %d", n)
+	}
+	timeout, err := strconv.ParseFloat(timeoutHeader, 64)
+	if err != nil || timeout <= 0 || timeout > 60 {
+		t.Errorf("unexpected %s header %q", scrapeTimeoutHeader, timeoutHeader)
+	}
+
+	mfChan = make(chan *dto.MetricFamily, 10)
+	if err := FetchMetricFamilies(server.URL+"/metrics", mfChan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if timeoutHeader != "" {
+		t.Errorf("unexpected %s header %q without deadline", scrapeTimeoutHeader, timeoutHeader)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	mfChan = make(chan *dto.MetricFamily, 10)
+	if err := FetchMetricFamiliesContext(ctx, server.URL+"/slow", mfChan, nil, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded for slow body, got %v", err)
+	}
+	if _, ok := <-mfChan; ok {
+		t.Error("expected closed channel")
+	}
+
+	// Nobody reads from the unbuffered channel.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	mfChan = make(chan *dto.MetricFamily)
+	if err := FetchMetricFamiliesContext(ctx, server.URL+"/metrics", mfChan, nil, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded for blocked channel, got %v", err)
+	}
+}
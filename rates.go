@@ -0,0 +1,274 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// Rates compares two scrapes of the same target, prev scraped at prevTime and
+// cur scraped at curTime, and returns Families like those in cur, but with the
+// values replaced by how they changed between the scrapes:
+//
+//   - Counters, and the counts, sums, and buckets of summaries and
+//     histograms, by their per-second rate, computed like the PromQL function
+//     rate does it, but only from these two samples and thus without
+//     extrapolation. A value that decreased is considered reset to zero in
+//     between, and so are all values of a series whose created timestamp is
+//     after the previous sample. Like in PromQL, a native histogram is reset
+//     as a whole if its count or any of its buckets decreased.
+//   - Gauges, untyped metrics, and the counts, sums, and buckets of gauge
+//     histograms by their delta.
+//
+// Samples with a timestamp use it instead of prevTime or curTime. Series that
+// are not in both scrapes, the quantiles of summaries, exemplars, and created
+// timestamps are left out, as are buckets of classic histograms that are not
+// in both scrapes. Families are merged by name as in DiffFamilies.
+func Rates(prev []*Family, prevTime time.Time, cur []*Family, curTime time.Time) []*Family {
+	prevFamilies, curFamilies := familiesByName(prev), familiesByName(cur)
+	var result []*Family
+	for _, f := range cur {
+		cf, ok := curFamilies[f.Name]
+		if !ok {
+			// Already added.
+			continue
+		}
+		delete(curFamilies, f.Name)
+		pf, ok := prevFamilies[f.Name]
+		if !ok || pf.Type != cf.Type {
+			continue
+		}
+		rf := &Family{Name: cf.Name, Help: cf.Help, Unit: cf.Unit, Type: cf.Type}
+		prevItems := map[string]any{}
+		for _, item := range pf.Metrics {
+			prevItems[labels.FromMap(itemLabels(item)).String()] = item
+		}
+		for _, item := range cf.Metrics {
+			prevItem, ok := prevItems[labels.FromMap(itemLabels(item)).String()]
+			if !ok {
+				continue
+			}
+			if r, ok := rateOf(cf.Type, prevItem, prevTime, item, curTime); ok {
+				rf.Metrics = append(rf.Metrics, r)
+			}
+		}
+		result = append(result, rf)
+	}
+	return result
+}
+
+// rater computes the change of the values of a single series.
+type rater struct {
+	// If seconds is positive, rates per second are computed, otherwise
+	// deltas.
+	seconds float64
+	// reset is whether the series has been reset as a whole.
+	reset bool
+}
+
+// change returns how the value changed from prev to cur. ok is false if either
+// is not a number.
+func (r rater) change(prev, cur string) (string, bool) {
+	p, errP := parseFloat(prev)
+	c, errC := parseFloat(cur)
+	if errP != nil || errC != nil {
+		return "", false
+	}
+	if r.seconds <= 0 {
+		return fmt.Sprint(c - p), true
+	}
+	if r.reset || c < p {
+		p = 0
+	}
+	return fmt.Sprint((c - p) / r.seconds), true
+}
+
+// rateOf returns the change of item relative to prevItem, which have to be of
+// the same type and belong to a Family of type typ. ok is false if there is no
+// meaningful result.
+func rateOf(typ string, prevItem any, prevTime time.Time, item any, curTime time.Time) (any, bool) {
+	var r rater
+	if typ == "COUNTER" || typ == "SUMMARY" || typ == "HISTOGRAM" {
+		prevTS, curTS, curCreated := itemTimestamp(prevItem), itemTimestamp(item), itemCreatedTimestamp(item)
+		start, end := sampleTime(prevTS, prevTime), sampleTime(curTS, curTime)
+		r.seconds = end.Sub(start).Seconds()
+		if r.seconds <= 0 {
+			return nil, false
+		}
+		if created, err := strconv.ParseInt(curCreated, 10, 64); err == nil && created > start.UnixMilli() {
+			r.reset = true
+		}
+	}
+	var ok bool
+	switch m := item.(type) {
+	case Metric:
+		pm, isMetric := prevItem.(Metric)
+		if !isMetric {
+			return nil, false
+		}
+		result := Metric{Labels: m.Labels, TimestampMs: m.TimestampMs}
+		result.Value, ok = r.change(pm.Value, m.Value)
+		return result, ok
+	case Summary:
+		ps, isSummary := prevItem.(Summary)
+		if !isSummary {
+			return nil, false
+		}
+		result := Summary{Labels: m.Labels, TimestampMs: m.TimestampMs, Quantiles: map[string]string{}}
+		var okSum bool
+		result.Count, ok = r.change(ps.Count, m.Count)
+		result.Sum, okSum = r.change(ps.Sum, m.Sum)
+		return result, ok && okSum
+	case Histogram:
+		ph, isHistogram := prevItem.(Histogram)
+		if !isHistogram {
+			return nil, false
+		}
+		return histogramRate(r, ph, m)
+	}
+	return nil, false
+}
+
+func histogramRate(r rater, ph, h Histogram) (Histogram, bool) {
+	result := Histogram{Labels: h.Labels, TimestampMs: h.TimestampMs}
+	buckets, native := h.nativeBuckets()
+	prevBuckets, prevNative := ph.nativeBuckets()
+	if native != prevNative {
+		return result, false
+	}
+	// A native histogram may have classic buckets, too.
+	classicBuckets, classic := h.Buckets.(map[string]string)
+	if native {
+		prevCounts := map[string]string{}
+		for _, b := range prevBuckets {
+			prevCounts[b.interval()] = b.Count
+		}
+		if r.seconds > 0 && !r.reset {
+			r.reset = nativeHistogramReset(ph.Count, h.Count, prevCounts, buckets)
+		}
+		rates := make([]NativeBucket, 0, len(buckets))
+		for _, b := range buckets {
+			pc, ok := prevCounts[b.interval()]
+			if !ok {
+				pc = "0"
+			}
+			rate, ok := r.change(pc, b.Count)
+			if !ok {
+				return result, false
+			}
+			b.Count = rate
+			rates = append(rates, b)
+		}
+		if classic {
+			result.NativeBuckets = rates
+		} else {
+			result.Buckets = rates
+		}
+	}
+	if classic || !native {
+		prevClassicBuckets, _ := ph.Buckets.(map[string]string)
+		rates := map[string]string{}
+		for ub, c := range classicBuckets {
+			if pc, ok := prevClassicBuckets[ub]; ok {
+				if rate, ok := r.change(pc, c); ok {
+					rates[ub] = rate
+				}
+			}
+		}
+		result.Buckets = rates
+	}
+	var okCount, okSum bool
+	result.Count, okCount = r.change(ph.Count, h.Count)
+	result.Sum, okSum = r.change(ph.Sum, h.Sum)
+	return result, okCount && okSum
+}
+
+// nativeHistogramReset returns whether a native histogram has been reset, i.e.
+// its count or one of its buckets decreased. A bucket missing from the current
+// histogram counts as decreased to zero.
+func nativeHistogramReset(prevCount, count string, prevCounts map[string]string, buckets []NativeBucket) bool {
+	if lessThan(count, prevCount) {
+		return true
+	}
+	counts := make(map[string]string, len(buckets))
+	for _, b := range buckets {
+		counts[b.interval()] = b.Count
+	}
+	for interval, pc := range prevCounts {
+		c, ok := counts[interval]
+		if !ok {
+			c = "0"
+		}
+		if lessThan(c, pc) {
+			return true
+		}
+	}
+	return false
+}
+
+// lessThan returns whether a is less than b, both parsed as numbers.
+func lessThan(a, b string) bool {
+	fa, errA := parseFloat(a)
+	fb, errB := parseFloat(b)
+	return errA == nil && errB == nil && fa < fb
+}
+
+// sampleTime returns the time of a sample with the provided timestamp in
+// milliseconds, or scrapeTime if it has none.
+func sampleTime(timestampMs string, scrapeTime time.Time) time.Time {
+	if ms, err := strconv.ParseInt(timestampMs, 10, 64); err == nil {
+		return time.UnixMilli(ms)
+	}
+	return scrapeTime
+}
+
+func itemLabels(item any) map[string]string {
+	switch m := item.(type) {
+	case Metric:
+		return m.Labels
+	case Summary:
+		return m.Labels
+	case Histogram:
+		return m.Labels
+	}
+	return nil
+}
+
+func itemTimestamp(item any) string {
+	switch m := item.(type) {
+	case Metric:
+		return m.TimestampMs
+	case Summary:
+		return m.TimestampMs
+	case Histogram:
+		return m.TimestampMs
+	}
+	return ""
+}
+
+func itemCreatedTimestamp(item any) string {
+	switch m := item.(type) {
+	case Metric:
+		return m.CreatedTimestampMs
+	case Summary:
+		return m.CreatedTimestampMs
+	case Histogram:
+		return m.CreatedTimestampMs
+	}
+	return ""
+}
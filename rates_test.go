@@ -0,0 +1,216 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRates(t *testing.T) {
+	prevTime := time.UnixMilli(1000000)
+	curTime := prevTime.Add(10 * time.Second)
+	prev := []*Family{
+		{
+			Name: "a_total",
+			Type: "COUNTER",
+			Metrics: []any{
+				Metric{Labels: map[string]string{"x": "normal"}, Value: "10"},
+				Metric{Labels: map[string]string{"x": "decreased"}, Value: "10"},
+				Metric{Labels: map[string]string{"x": "created"}, CreatedTimestampMs: "0", Value: "10"},
+				Metric{Labels: map[string]string{"x": "timestamped"}, TimestampMs: "1000000", Value: "10"},
+				Metric{Labels: map[string]string{"x": "gone"}, Value: "10"},
+			},
+		},
+		{Name: "b", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "5"}}},
+		{
+			Name: "c",
+			Type: "SUMMARY",
+			Metrics: []any{
+				Summary{Labels: map[string]string{}, Quantiles: map[string]string{"0.5": "1"}, Count: "10", Sum: "20"},
+			},
+		},
+		{
+			Name: "d",
+			Type: "HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels:  map[string]string{"kind": "classic"},
+					Buckets: map[string]string{"1": "5", "+Inf": "10"},
+					Count:   "10",
+					Sum:     "20",
+				},
+				Histogram{
+					Labels:  map[string]string{"kind": "native"},
+					Buckets: [][]any{{uint64(0), "1", "2", "4"}, {uint64(0), "2", "4", "6"}},
+					Count:   "10",
+					Sum:     "20",
+				},
+				Histogram{
+					Labels:  map[string]string{"kind": "native reset"},
+					Buckets: [][]any{{uint64(0), "1", "2", "4"}, {uint64(0), "2", "4", "6"}},
+					Count:   "10",
+					Sum:     "20",
+				},
+			},
+		},
+		{Name: "gone", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "5"}}},
+	}
+	cur := []*Family{
+		{
+			Name: "a_total",
+			Type: "COUNTER",
+			Metrics: []any{
+				Metric{Labels: map[string]string{"x": "normal"}, Value: "30", Exemplars: []Exemplar{{Value: "1"}}},
+				Metric{Labels: map[string]string{"x": "decreased"}, Value: "5"},
+				Metric{Labels: map[string]string{"x": "created"}, CreatedTimestampMs: "1005000", Value: "20"},
+				Metric{Labels: map[string]string{"x": "timestamped"}, TimestampMs: "1004000", Value: "18"},
+				Metric{Labels: map[string]string{"x": "new"}, Value: "10"},
+			},
+		},
+		{Name: "b", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "2.5"}}},
+		{
+			Name: "c",
+			Type: "SUMMARY",
+			Metrics: []any{
+				Summary{Labels: map[string]string{}, Quantiles: map[string]string{"0.5": "2"}, Count: "20", Sum: "50"},
+			},
+		},
+		{
+			Name: "d",
+			Type: "HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels:  map[string]string{"kind": "classic"},
+					Buckets: map[string]string{"1": "5", "2": "7", "+Inf": "20"},
+					Count:   "20",
+					Sum:     "40",
+				},
+				Histogram{
+					Labels:  map[string]string{"kind": "native"},
+					Buckets: []NativeBucket{{0, "1", "2", "4"}, {0, "2", "4", "16"}, {0, "4", "8", "1"}},
+					Count:   "21",
+					Sum:     "30",
+				},
+				Histogram{
+					Labels:  map[string]string{"kind": "native reset"},
+					Buckets: []NativeBucket{{0, "1", "2", "3"}, {0, "2", "4", "17"}},
+					Count:   "20",
+					Sum:     "30",
+				},
+			},
+		},
+		{Name: "new", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "5"}}},
+	}
+	expected := []*Family{
+		{
+			Name: "a_total",
+			Type: "COUNTER",
+			Metrics: []any{
+				Metric{Labels: map[string]string{"x": "normal"}, Value: "2"},
+				Metric{Labels: map[string]string{"x": "decreased"}, Value: "0.5"},
+				Metric{Labels: map[string]string{"x": "created"}, Value: "2"},
+				Metric{Labels: map[string]string{"x": "timestamped"}, TimestampMs: "1004000", Value: "2"},
+			},
+		},
+		{Name: "b", Type: "GAUGE", Metrics: []any{Metric{Labels: map[string]string{}, Value: "-2.5"}}},
+		{
+			Name: "c",
+			Type: "SUMMARY",
+			Metrics: []any{
+				Summary{Labels: map[string]string{}, Quantiles: map[string]string{}, Count: "1", Sum: "3"},
+			},
+		},
+		{
+			Name: "d",
+			Type: "HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels:  map[string]string{"kind": "classic"},
+					Buckets: map[string]string{"1": "0", "+Inf": "1"},
+					Count:   "1",
+					Sum:     "2",
+				},
+				Histogram{
+					Labels:  map[string]string{"kind": "native"},
+					Buckets: []NativeBucket{{0, "1", "2", "0"}, {0, "2", "4", "1"}, {0, "4", "8", "0.1"}},
+					Count:   "1.1",
+					Sum:     "1",
+				},
+				Histogram{
+					Labels:  map[string]string{"kind": "native reset"},
+					Buckets: []NativeBucket{{0, "1", "2", "0.3"}, {0, "2", "4", "1.7"}},
+					Count:   "2",
+					Sum:     "3",
+				},
+			},
+		},
+	}
+	if got := Rates(prev, prevTime, cur, curTime); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected\n%s\ngot\n%s", spew.Sdump(expected), spew.Sdump(got))
+	}
+}
+
+func TestRatesOpenMetricsGaugeHistogram(t *testing.T) {
+	parse := func(in string) []*Family {
+		mfChan := make(chan *dto.MetricFamily, 10)
+		if err := ParseReader(strings.NewReader(in), mfChan); err != nil {
+			t.Fatal(err)
+		}
+		var result []*Family
+		for mf := range mfChan {
+			result = append(result, NewFamily(mf))
+		}
+		return result
+	}
+	prev := parse(`# TYPE queue_size gaugehistogram
+queue_size_bucket{le="1"} 2
+queue_size_bucket{le="+Inf"} 6
+queue_size_gcount 6
+queue_size_gsum 23.5
+# EOF
+`)
+	cur := parse(`# TYPE queue_size gaugehistogram
+queue_size_bucket{le="1"} 3
+queue_size_bucket{le="+Inf"} 4
+queue_size_gcount 4
+queue_size_gsum 10
+# EOF
+`)
+	// Gauge histograms may decrease, so they get deltas rather than rates.
+	expected := []*Family{
+		{
+			Name: "queue_size",
+			Type: "GAUGE_HISTOGRAM",
+			Metrics: []any{
+				Histogram{
+					Labels:  map[string]string{},
+					Buckets: map[string]string{"1": "1", "+Inf": "-2"},
+					Count:   "-2",
+					Sum:     "-13.5",
+				},
+			},
+		},
+	}
+	prevTime := time.UnixMilli(1000000)
+	output := Rates(prev, prevTime, cur, prevTime.Add(10*time.Second))
+	if !reflect.DeepEqual(expected, output) {
+		t.Errorf("expected\n%s\ngot\n%s", spew.Sdump(expected), spew.Sdump(output))
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"fmt"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	model "github.com/prometheus/prometheus/model/histogram"
+
+	"github.com/prometheus/prom2json/histogram"
+)
+
+// NativeHistogramFormat determines how native histograms are represented in a
+// Family.
+type NativeHistogramFormat int
+
+// The supported NativeHistogramFormats.
+const (
+	// NativeBuckets represents a native histogram by its Buckets, like the
+	// Prometheus query API does it. This is what NewFamily does.
+	NativeBuckets NativeHistogramFormat = iota
+	// NativeRaw represents a native histogram by its Raw structure only.
+	NativeRaw
+	// NativeBucketsAndRaw represents a native histogram by both its Buckets
+	// and its Raw structure.
+	NativeBucketsAndRaw
+)
+
+// RawNativeHistogram is the structure of a native histogram as exposed in the
+// protobuf format, with the counts of the buckets in addition to the deltas
+// between them. The buckets are numbered as described for the spans of a
+// native histogram in the protobuf format.
+type RawNativeHistogram struct {
+	Schema        int32  `json:"schema"`
+	ZeroThreshold string `json:"zero_threshold"`
+	ZeroCount     string `json:"zero_count"`
+	PositiveSpans []Span `json:"positive_spans,omitempty"`
+	// PositiveDeltas are only set for an integer histogram.
+	PositiveDeltas []string `json:"positive_deltas,omitempty"`
+	PositiveCounts []string `json:"positive_counts,omitempty"`
+	NegativeSpans  []Span   `json:"negative_spans,omitempty"`
+	// NegativeDeltas are only set for an integer histogram.
+	NegativeDeltas []string `json:"negative_deltas,omitempty"`
+	NegativeCounts []string `json:"negative_counts,omitempty"`
+}
+
+// Span is a span of consecutive buckets of a native histogram.
+type Span struct {
+	Offset int32  `json:"offset"`
+	Length uint32 `json:"length"`
+}
+
+// newRawNativeHistogram creates a RawNativeHistogram from exactly one of h and
+// fh.
+func newRawNativeHistogram(h *model.Histogram, fh *model.FloatHistogram) *RawNativeHistogram {
+	if h != nil {
+		return &RawNativeHistogram{
+			Schema:         h.Schema,
+			ZeroThreshold:  fmt.Sprint(h.ZeroThreshold),
+			ZeroCount:      fmt.Sprint(h.ZeroCount),
+			PositiveSpans:  makeSpans(h.PositiveSpans),
+			PositiveDeltas: formatNumbers(h.PositiveBuckets),
+			PositiveCounts: formatNumbers(fromDeltas(h.PositiveBuckets)),
+			NegativeSpans:  makeSpans(h.NegativeSpans),
+			NegativeDeltas: formatNumbers(h.NegativeBuckets),
+			NegativeCounts: formatNumbers(fromDeltas(h.NegativeBuckets)),
+		}
+	}
+	return &RawNativeHistogram{
+		Schema:         fh.Schema,
+		ZeroThreshold:  fmt.Sprint(fh.ZeroThreshold),
+		ZeroCount:      fmt.Sprint(fh.ZeroCount),
+		PositiveSpans:  makeSpans(fh.PositiveSpans),
+		PositiveCounts: formatNumbers(fh.PositiveBuckets),
+		NegativeSpans:  makeSpans(fh.NegativeSpans),
+		NegativeCounts: formatNumbers(fh.NegativeBuckets),
+	}
+}
+
+// modelHistogram returns the histogram with the provided count and sum as
+// exactly one of a model.Histogram, if the histogram has deltas, and a
+// model.FloatHistogram.
+func (r *RawNativeHistogram) modelHistogram(count, sum string) (*model.Histogram, *model.FloatHistogram, error) {
+	s, err := parseFloat(sum)
+	if err != nil {
+		return nil, nil, err
+	}
+	zeroThreshold, err := parseFloat(r.ZeroThreshold)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(r.PositiveDeltas) > 0 || len(r.NegativeDeltas) > 0 ||
+		len(r.PositiveCounts)+len(r.NegativeCounts) == 0 && isUint(count) && isUint(r.ZeroCount) {
+		h := &model.Histogram{
+			Schema:        r.Schema,
+			Sum:           s,
+			ZeroThreshold: zeroThreshold,
+			PositiveSpans: makeModelSpans(r.PositiveSpans),
+			NegativeSpans: makeModelSpans(r.NegativeSpans),
+		}
+		if h.Count, err = strconv.ParseUint(count, 10, 64); err != nil {
+			return nil, nil, fmt.Errorf("invalid count of integer histogram: %w", err)
+		}
+		if h.ZeroCount, err = strconv.ParseUint(r.ZeroCount, 10, 64); err != nil {
+			return nil, nil, fmt.Errorf("invalid zero count of integer histogram: %w", err)
+		}
+		if h.PositiveBuckets, err = parseDeltas(r.PositiveDeltas); err != nil {
+			return nil, nil, err
+		}
+		if h.NegativeBuckets, err = parseDeltas(r.NegativeDeltas); err != nil {
+			return nil, nil, err
+		}
+		return h, nil, nil
+	}
+	fh := &model.FloatHistogram{
+		Schema:        r.Schema,
+		Sum:           s,
+		ZeroThreshold: zeroThreshold,
+		PositiveSpans: makeModelSpans(r.PositiveSpans),
+		NegativeSpans: makeModelSpans(r.NegativeSpans),
+	}
+	if fh.Count, err = parseFloat(count); err != nil {
+		return nil, nil, err
+	}
+	if fh.ZeroCount, err = parseFloat(r.ZeroCount); err != nil {
+		return nil, nil, err
+	}
+	if fh.PositiveBuckets, err = parseCounts(r.PositiveCounts); err != nil {
+		return nil, nil, err
+	}
+	if fh.NegativeBuckets, err = parseCounts(r.NegativeCounts); err != nil {
+		return nil, nil, err
+	}
+	return nil, fh, nil
+}
+
+// dtoHistogram returns the histogram with the provided count and sum as a
+// Histogram proto message.
+func (r *RawNativeHistogram) dtoHistogram(count, sum string) (*dto.Histogram, error) {
+	h, fh, err := r.modelHistogram(count, sum)
+	if err != nil {
+		return nil, err
+	}
+	return histogram.NewDTOHistogram(h, fh), nil
+}
+
+// nativeBuckets returns the buckets of the histogram like the Prometheus query
+// API does it.
+func (r *RawNativeHistogram) nativeBuckets(count, sum string) ([]NativeBucket, error) {
+	h, fh, err := r.modelHistogram(count, sum)
+	if err != nil {
+		return nil, err
+	}
+	if h != nil {
+		return newNativeBuckets(histogram.BucketsAsJson(histogram.GetAPIBuckets(h)))
+	}
+	return newNativeBuckets(histogram.BucketsAsJson(histogram.GetAPIFloatBuckets(fh)))
+}
+
+func makeSpans(spans []model.Span) []Span {
+	if len(spans) == 0 {
+		return nil
+	}
+	result := make([]Span, len(spans))
+	for i, s := range spans {
+		result[i] = Span{Offset: s.Offset, Length: s.Length}
+	}
+	return result
+}
+
+func makeModelSpans(spans []Span) []model.Span {
+	if len(spans) == 0 {
+		return nil
+	}
+	result := make([]model.Span, len(spans))
+	for i, s := range spans {
+		result[i] = model.Span{Offset: s.Offset, Length: s.Length}
+	}
+	return result
+}
+
+func fromDeltas(deltas []int64) []int64 {
+	if len(deltas) == 0 {
+		return nil
+	}
+	counts := make([]int64, len(deltas))
+	var count int64
+	for i, d := range deltas {
+		count += d
+		counts[i] = count
+	}
+	return counts
+}
+
+func formatNumbers[N int64 | float64](numbers []N) []string {
+	if len(numbers) == 0 {
+		return nil
+	}
+	result := make([]string, len(numbers))
+	for i, n := range numbers {
+		result[i] = fmt.Sprint(n)
+	}
+	return result
+}
+
+func parseDeltas(deltas []string) ([]int64, error) {
+	if len(deltas) == 0 {
+		return nil, nil
+	}
+	result := make([]int64, len(deltas))
+	for i, d := range deltas {
+		var err error
+		if result[i], err = strconv.ParseInt(d, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid bucket delta: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func parseCounts(counts []string) ([]float64, error) {
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	result := make([]float64, len(counts))
+	for i, c := range counts {
+		var err error
+		if result[i], err = parseFloat(c); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func isUint(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
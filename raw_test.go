@@ -0,0 +1,125 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRawNativeHistogram(t *testing.T) {
+	for name, tc := range map[string]struct {
+		h        *dto.Histogram
+		expected *RawNativeHistogram
+	}{
+		"integer histogram": {
+			h: &dto.Histogram{
+				SampleCount:   uintPtr(12),
+				SampleSum:     floatPtr(-3.5),
+				Schema:        int32Ptr(2),
+				ZeroThreshold: floatPtr(0.001),
+				ZeroCount:     uintPtr(2),
+				NegativeSpan: []*dto.BucketSpan{
+					createBucketSpan(-2, 2),
+					createBucketSpan(3, 1),
+				},
+				NegativeDelta: []int64{1, 1, -1},
+				PositiveSpan: []*dto.BucketSpan{
+					createBucketSpan(5, 1),
+				},
+				PositiveDelta: []int64{5},
+			},
+			expected: &RawNativeHistogram{
+				Schema:         2,
+				ZeroThreshold:  "0.001",
+				ZeroCount:      "2",
+				PositiveSpans:  []Span{{Offset: 5, Length: 1}},
+				PositiveDeltas: []string{"5"},
+				PositiveCounts: []string{"5"},
+				NegativeSpans:  []Span{{Offset: -2, Length: 2}, {Offset: 3, Length: 1}},
+				NegativeDeltas: []string{"1", "1", "-1"},
+				NegativeCounts: []string{"1", "2", "1"},
+			},
+		},
+		"float histogram": {
+			h: &dto.Histogram{
+				SampleCountFloat: floatPtr(4.5),
+				SampleSum:        floatPtr(100),
+				Schema:           int32Ptr(-2),
+				ZeroThreshold:    floatPtr(0),
+				ZeroCountFloat:   floatPtr(0),
+				PositiveSpan: []*dto.BucketSpan{
+					createBucketSpan(1, 2),
+				},
+				PositiveCount: []float64{1.5, 3},
+			},
+			expected: &RawNativeHistogram{
+				Schema:         -2,
+				ZeroThreshold:  "0",
+				ZeroCount:      "0",
+				PositiveSpans:  []Span{{Offset: 1, Length: 2}},
+				PositiveCounts: []string{"1.5", "3"},
+			},
+		},
+	} {
+		mf := &dto.MetricFamily{
+			Name:   strPtr("histogram"),
+			Type:   metricTypePtr(dto.MetricType_HISTOGRAM),
+			Metric: []*dto.Metric{{Histogram: tc.h}},
+		}
+		buckets := NewFamily(mf).Metrics[0].(Histogram)
+		if buckets.Raw != nil {
+			t.Errorf("%s: expected no raw histogram by default, got %s", name, spew.Sdump(buckets.Raw))
+		}
+		for _, format := range []NativeHistogramFormat{NativeRaw, NativeBucketsAndRaw} {
+			h := NewFamilyWithOptions(mf, FamilyOptions{NativeFormat: format}).Metrics[0].(Histogram)
+			if !reflect.DeepEqual(tc.expected, h.Raw) {
+				t.Errorf("%s, format %d: expected\n%s\ngot\n%s", name, format, spew.Sdump(tc.expected), spew.Sdump(h.Raw))
+			}
+			if format == NativeBucketsAndRaw && !reflect.DeepEqual(buckets.Buckets, h.Buckets) {
+				t.Errorf("%s, format %d: expected buckets\n%s\ngot\n%s", name, format, spew.Sdump(buckets.Buckets), spew.Sdump(h.Buckets))
+			}
+			if format == NativeRaw && h.Buckets != nil {
+				t.Errorf("%s, format %d: expected no buckets, got %s", name, format, spew.Sdump(h.Buckets))
+			}
+			expectedBuckets, _ := buckets.nativeBuckets()
+			if got, native := h.nativeBuckets(); !native || !reflect.DeepEqual(expectedBuckets, got) {
+				t.Errorf("%s, format %d: expected native buckets\n%s\ngot\n%s", name, format, spew.Sdump(expectedBuckets), spew.Sdump(got))
+			}
+
+			// The raw histogram survives a round trip via JSON exactly.
+			data, err := json.Marshal(NewFamilyWithOptions(mf, FamilyOptions{NativeFormat: format}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var f Family
+			if err := json.Unmarshal(data, &f); err != nil {
+				t.Fatal(err)
+			}
+			output, err := NewMetricFamily(&f)
+			if err != nil {
+				t.Errorf("%s, format %d: conversion to MetricFamily failed: %v", name, format, err)
+				continue
+			}
+			if !proto.Equal(mf, output) {
+				t.Errorf("%s, format %d: round trip via JSON failed:\nexpected:\n%s\n\nactual:\n%s", name, format, mf, output)
+			}
+		}
+	}
+}
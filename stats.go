@@ -0,0 +1,137 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prometheus/prom2json/histogram"
+)
+
+// ErrHistogramStats is wrapped by the errors returned by AddHistogramStats for
+// histograms whose stats could not be calculated.
+var ErrHistogramStats = errors.New("calculating histogram stats failed")
+
+// AddHistogramStats sets the Quantiles of each Histogram of the Family to
+// estimates of the provided quantiles (0 ≤ q ≤ 1), keyed like the Quantiles of
+// a Summary, and, if mean is true, its Mean to Sum divided by Count. The
+// quantiles are estimated like the PromQL function histogram_quantile does it,
+// see histogram.BucketQuantile and histogram.Quantile.
+//
+// A Histogram whose stats cannot be calculated is left unchanged, which does
+// not keep the stats from being added to the other Histograms. The returned
+// error then wraps ErrHistogramStats once per such Histogram.
+func (f *Family) AddHistogramStats(quantiles []float64, mean bool) error {
+	var errs []error
+	for i, item := range f.Metrics {
+		h, ok := item.(Histogram)
+		if !ok {
+			continue
+		}
+		if err := h.addStats(quantiles, mean); err != nil {
+			errs = append(errs, fmt.Errorf("%w for series %s of metric family %q: %w",
+				ErrHistogramStats, labels.FromMap(h.Labels), f.Name, err))
+			continue
+		}
+		f.Metrics[i] = h
+	}
+	return errors.Join(errs...)
+}
+
+// addStats works like AddHistogramStats for a single Histogram.
+func (h *Histogram) addStats(quantiles []float64, mean bool) error {
+	if len(quantiles) > 0 {
+		estimate, err := newQuantileEstimator(*h)
+		if err != nil {
+			return err
+		}
+		h.Quantiles = make(map[string]string, len(quantiles))
+		for _, q := range quantiles {
+			h.Quantiles[fmt.Sprint(q)] = fmt.Sprint(estimate(q))
+		}
+	}
+	if mean {
+		count, err := parseFloat(h.Count)
+		if err != nil {
+			return err
+		}
+		sum, err := parseFloat(h.Sum)
+		if err != nil {
+			return err
+		}
+		h.Mean = fmt.Sprint(math.NaN())
+		if count != 0 {
+			h.Mean = fmt.Sprint(sum / count)
+		}
+	}
+	return nil
+}
+
+// newQuantileEstimator returns a function estimating quantiles of h.
+func newQuantileEstimator(h Histogram) (func(q float64) float64, error) {
+	count, err := parseFloat(h.Count)
+	if err != nil {
+		return nil, err
+	}
+	if h.Raw != nil {
+		ih, fh, err := h.Raw.modelHistogram(h.Count, h.Sum)
+		if err != nil {
+			return nil, err
+		}
+		if ih != nil {
+			fh = ih.ToFloat(nil)
+		}
+		return func(q float64) float64 { return histogram.Quantile(q, fh) }, nil
+	}
+	if buckets, native := h.nativeBuckets(); native {
+		apiBuckets, err := parseNativeBuckets(buckets)
+		if err != nil {
+			return nil, err
+		}
+		fh, err := histogram.FromAPIFloatBuckets(apiBuckets)
+		if err != nil {
+			return nil, err
+		}
+		if fh.Sum, err = parseFloat(h.Sum); err != nil {
+			return nil, err
+		}
+		fh.Count = count
+		return func(q float64) float64 { return histogram.Quantile(q, fh) }, nil
+	}
+	classicBuckets, _ := h.Buckets.(map[string]string)
+	buckets := make([]histogram.Bucket, 0, len(classicBuckets)+1)
+	hasInf := false
+	for ub, c := range classicBuckets {
+		upperBound, err := parseFloat(ub)
+		if err != nil {
+			return nil, err
+		}
+		cumulativeCount, err := parseFloat(c)
+		if err != nil {
+			return nil, err
+		}
+		hasInf = hasInf || math.IsInf(upperBound, +1)
+		buckets = append(buckets, histogram.Bucket{UpperBound: upperBound, CumulativeCount: cumulativeCount})
+	}
+	if !hasInf {
+		// The +Inf bucket is implied by the count, as it is usually not
+		// exposed in the protobuf format.
+		buckets = append(buckets, histogram.Bucket{UpperBound: math.Inf(+1), CumulativeCount: count})
+	}
+	return func(q float64) float64 { return histogram.BucketQuantile(q, buckets) }, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom2json
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestAddHistogramStats(t *testing.T) {
+	f := &Family{
+		Name: "a",
+		Type: "HISTOGRAM",
+		Metrics: []any{
+			Histogram{
+				Labels:  map[string]string{"kind": "classic"},
+				Buckets: map[string]string{"1": "2", "2": "6", "+Inf": "8"},
+				Count:   "8",
+				Sum:     "12",
+			},
+			Histogram{
+				Labels:  map[string]string{"kind": "classic without +Inf"},
+				Buckets: map[string]string{"1": "2", "2": "6"},
+				Count:   "8",
+				Sum:     "12",
+			},
+			Histogram{
+				Labels:  map[string]string{"kind": "native"},
+				Buckets: [][]any{{uint64(0), "1", "2", "4"}, {uint64(0), "2", "4", "4"}},
+				Count:   "8",
+				Sum:     "20",
+			},
+			Histogram{
+				Labels:  map[string]string{"kind": "empty"},
+				Buckets: map[string]string{"1": "0"},
+				Count:   "0",
+				Sum:     "0",
+			},
+		},
+	}
+	expected := &Family{
+		Name: "a",
+		Type: "HISTOGRAM",
+		Metrics: []any{
+			Histogram{
+				Labels:    map[string]string{"kind": "classic"},
+				Buckets:   map[string]string{"1": "2", "2": "6", "+Inf": "8"},
+				Count:     "8",
+				Sum:       "12",
+				Quantiles: map[string]string{"0.25": "1", "0.5": "1.5", "0.9": "2"},
+				Mean:      "1.5",
+			},
+			Histogram{
+				Labels:    map[string]string{"kind": "classic without +Inf"},
+				Buckets:   map[string]string{"1": "2", "2": "6"},
+				Count:     "8",
+				Sum:       "12",
+				Quantiles: map[string]string{"0.25": "1", "0.5": "1.5", "0.9": "2"},
+				Mean:      "1.5",
+			},
+			Histogram{
+				Labels:    map[string]string{"kind": "native"},
+				Buckets:   [][]any{{uint64(0), "1", "2", "4"}, {uint64(0), "2", "4", "4"}},
+				Count:     "8",
+				Sum:       "20",
+				Quantiles: map[string]string{"0.25": "1.414213562373095", "0.5": "2", "0.9": "3.4822022531844965"},
+				Mean:      "2.5",
+			},
+			Histogram{
+				Labels:    map[string]string{"kind": "empty"},
+				Buckets:   map[string]string{"1": "0"},
+				Count:     "0",
+				Sum:       "0",
+				Quantiles: map[string]string{"0.25": "NaN", "0.5": "NaN", "0.9": "NaN"},
+				Mean:      "NaN",
+			},
+		},
+	}
+	if err := f.AddHistogramStats([]float64{0.25, 0.5, 0.9}, true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, expected) {
+		t.Errorf("expected\n%s\ngot\n%s", spew.Sdump(expected), spew.Sdump(f))
+	}
+}
+
+func TestAddHistogramStatsZeroThresholdZero(t *testing.T) {
+	// A zero threshold of 0 results in a zero bucket of [-0,0].
+	f := NewFamily(&dto.MetricFamily{
+		Name: strPtr("a"),
+		Type: metricTypePtr(dto.MetricType_HISTOGRAM),
+		Metric: []*dto.Metric{{
+			Histogram: &dto.Histogram{
+				SampleCount:   uintPtr(4),
+				SampleSum:     floatPtr(3),
+				Schema:        int32Ptr(0),
+				ZeroThreshold: floatPtr(0),
+				ZeroCount:     uintPtr(2),
+				PositiveSpan:  []*dto.BucketSpan{createBucketSpan(0, 2)},
+				PositiveDelta: []int64{1, 0},
+			},
+		}},
+	})
+	if err := f.AddHistogramStats([]float64{0.25, 0.75}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"0.25": "0", "0.75": "1"}
+	if got := f.Metrics[0].(Histogram).Quantiles; !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestAddHistogramStatsErrors(t *testing.T) {
+	f := &Family{
+		Name: "a",
+		Type: "HISTOGRAM",
+		Metrics: []any{
+			Histogram{
+				Labels:  map[string]string{"kind": "invalid"},
+				Buckets: []NativeBucket{{Boundaries: 0, Lower: "-1", Upper: "2", Count: "1"}},
+				Count:   "1",
+				Sum:     "1",
+			},
+			Histogram{
+				Labels:  map[string]string{"kind": "valid"},
+				Buckets: map[string]string{"1": "2", "+Inf": "2"},
+				Count:   "2",
+				Sum:     "1",
+			},
+		},
+	}
+	err := f.AddHistogramStats([]float64{0.5}, true)
+	if !errors.Is(err, ErrHistogramStats) {
+		t.Errorf("expected an error wrapping ErrHistogramStats, got %v", err)
+	}
+	if h := f.Metrics[0].(Histogram); h.Quantiles != nil || h.Mean != "" {
+		t.Errorf("expected no stats for the invalid histogram, got %s", spew.Sdump(h))
+	}
+	if h := f.Metrics[1].(Histogram); h.Quantiles["0.5"] != "0.5" || h.Mean != "0.5" {
+		t.Errorf("expected stats for the valid histogram, got %s", spew.Sdump(h))
+	}
+}